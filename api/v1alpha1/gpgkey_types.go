@@ -17,7 +17,16 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"path"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// GPGKeyConditionExpiring is the condition type set on a GPGKey once its
+	// expiry date falls within the warning window, or has already passed.
+	GPGKeyConditionExpiring = "Expiring"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -29,8 +38,82 @@ type GPGKeySpec struct {
 	// Important: Run "make" to regenerate code after modifying this file
 
 	// Foo is an example field of GPGKey. Edit gpgkey_types.go to remove/update
-	ArmoredPrivateKey string `json:"armored_private_key"`
-	Passphrase        string `json:"passphrase"`
+	// +kubebuilder:validation:Optional
+	ArmoredPrivateKey string `json:"armored_private_key,omitempty"`
+	// +kubebuilder:validation:Optional
+	Passphrase string `json:"passphrase,omitempty"`
+
+	// Generate makes the operator create the keypair instead of importing
+	// armored_private_key, the private key and its passphrase are kept in an
+	// owned Secret and only the public key is published
+	// +kubebuilder:validation:Optional
+	Generate *GPGKeyGenerateSpec `json:"generate,omitempty"`
+
+	// AllowedSecrets restricts which SopsSecrets and SopsFiles may use this
+	// GPGKey, any of them allowed to reference it may use it when unset
+	// +kubebuilder:validation:Optional
+	AllowedSecrets *GPGKeyAllowedSecrets `json:"allowedSecrets,omitempty"`
+}
+
+// GPGKeyAllowedSecrets selects the SopsSecrets and SopsFiles allowed to use
+// a GPGKey. They have to match both the selector and the name pattern when
+// both are set.
+type GPGKeyAllowedSecrets struct {
+	// Selector matches the labels of the SopsSecrets
+	// +kubebuilder:validation:Optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// NamePattern is a shell glob matched against the SopsSecret names, e.g. payments-*
+	// +kubebuilder:validation:Optional
+	NamePattern string `json:"namePattern,omitempty"`
+}
+
+// Allows reports whether the SopsSecret or SopsFile matches the allowed secrets
+func (a *GPGKeyAllowedSecrets) Allows(object metav1.Object) (bool, error) {
+	if a == nil {
+		return true, nil
+	}
+	if a.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(a.Selector)
+		if err != nil {
+			return false, err
+		}
+		if !selector.Matches(labels.Set(object.GetLabels())) {
+			return false, nil
+		}
+	}
+	if a.NamePattern != "" {
+		return path.Match(a.NamePattern, object.GetName())
+	}
+	return true, nil
+}
+
+// GPGKeyGenerateSpec defines the keypair generated by the operator.
+// Changes after the key has been generated are ignored.
+type GPGKeyGenerateSpec struct {
+	// Algorithm of the generated key
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=rsa;ed25519
+	// +kubebuilder:default=rsa
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// KeySize in bits, only used by rsa keys
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=2048
+	// +kubebuilder:validation:Maximum=4096
+	// +kubebuilder:default=4096
+	KeySize int `json:"keySize,omitempty"`
+
+	// UID of the key, e.g. "Team A <team-a@example.com>"
+	// +kubebuilder:validation:Required
+	UID string `json:"uid"`
+
+	// Expiry of the key in the gpg Expire-Date format, e.g. 1y, 180d or
+	// 2030-01-31, 0 means never
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^(0|[0-9]+[dwmy]?|[0-9]{4}-[0-9]{2}-[0-9]{2})$`
+	// +kubebuilder:default="0"
+	Expiry string `json:"expiry,omitempty"`
 }
 
 // GPGKeyStatus defines the observed state of GPGKey
@@ -38,12 +121,31 @@ type GPGKeyStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 	Message string `json:"message"`
+
+	// ExpiresAt is the time after which the imported key can no longer be used
+	// for decryption, unset when the key never expires
+	// +kubebuilder:validation:Optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// Fingerprint of the imported key, to be used with sops --pgp
+	// +kubebuilder:validation:Optional
+	Fingerprint string `json:"fingerprint,omitempty"`
+
+	// PublicKey is the armored public key of a generated key
+	// +kubebuilder:validation:Optional
+	PublicKey string `json:"publicKey,omitempty"`
+
+	// Conditions of the GPGKey, such as Expiring
+	// +kubebuilder:validation:Optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // GPGKey is the Schema for the gpgkeys API
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
+//+kubebuilder:printcolumn:name="Fingerprint",type=string,JSONPath=`.status.fingerprint`,priority=1
+//+kubebuilder:printcolumn:name="Expires",type="date",JSONPath=".status.expiresAt"
 //+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 type GPGKey struct {
 	metav1.TypeMeta   `json:",inline"`
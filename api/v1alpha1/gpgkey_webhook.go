@@ -21,7 +21,10 @@ import (
 	"fmt"
 	passwordValidator "github.com/go-passwd/validator"
 	"github.com/snapp-incubator/sops-operator/lang"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"path"
+	"regexp"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -32,6 +35,9 @@ import (
 var (
 	gpgKeyLog                        = logf.Log.WithName("gpgkey-resource")
 	gPGKeyArmoredPrivateKeyMinLength = 1024
+	// gpgKeyExpiryRegexp matches the Expire-Date formats of gpg, same as the
+	// pattern of generate.expiry
+	gpgKeyExpiryRegexp = regexp.MustCompile(`^(0|[0-9]+[dwmy]?|[0-9]{4}-[0-9]{2}-[0-9]{2})$`)
 )
 
 func (r *GPGKey) SetupWebhookWithManager(mgr ctrl.Manager) error {
@@ -89,6 +95,12 @@ func (r *GPGKey) ValidateDelete() error {
 }
 
 func (r *GPGKey) ValidateGPGKey() error {
+	if err := r.validateAllowedSecrets(); err != nil {
+		return err
+	}
+	if r.Spec.Generate != nil {
+		return r.validateGenerate()
+	}
 	passValidObj := GetPasswordValidator()
 	if err := passValidObj.Validate(r.Spec.Passphrase); err != nil {
 		return err
@@ -102,6 +114,37 @@ func (r *GPGKey) ValidateGPGKey() error {
 	return nil
 }
 
+func (r *GPGKey) validateGenerate() error {
+	if r.Spec.ArmoredPrivateKey != "" || r.Spec.Passphrase != "" {
+		return fmt.Errorf(lang.ErrGPGKeySpecGenerateWithKey)
+	}
+	uid := strings.TrimSpace(r.Spec.Generate.UID)
+	if uid == "" || strings.ContainsAny(uid, "\r\n") {
+		return fmt.Errorf(lang.ErrGPGKeySpecGenerateUID)
+	}
+	// the expiry is written to the gpg batch parameters as is
+	if expiry := r.Spec.Generate.Expiry; expiry != "" && !gpgKeyExpiryRegexp.MatchString(expiry) {
+		return fmt.Errorf(lang.ErrGPGKeySpecGenerateExpiry)
+	}
+	return nil
+}
+
+func (r *GPGKey) validateAllowedSecrets() error {
+	allowedSecrets := r.Spec.AllowedSecrets
+	if allowedSecrets == nil {
+		return nil
+	}
+	if allowedSecrets.Selector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(allowedSecrets.Selector); err != nil {
+			return fmt.Errorf(lang.ErrGPGKeySpecAllowedSecretsInvalid)
+		}
+	}
+	if _, err := path.Match(allowedSecrets.NamePattern, ""); err != nil {
+		return fmt.Errorf(lang.ErrGPGKeySpecAllowedSecretsInvalid)
+	}
+	return nil
+}
+
 func GetPasswordValidator() *passwordValidator.Validator {
 	return passwordValidator.New(
 		passwordValidator.MinLength(14, errors.New(lang.ErrGPGKeySpecPassphraseLength)),
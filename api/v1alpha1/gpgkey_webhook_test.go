@@ -136,6 +136,80 @@ var _ = Describe("GPGKey webhook", func() {
 		})
 	})
 
+	Context("When creating a generated GPGKey", func() {
+		It("Should fail if private key or passphrase are also provided", func() {
+			By("Creating a generated GPGKey with a passphrase")
+			fooGPGKeyObj := &GPGKey{
+				TypeMeta:   fooGPGKeyMeta.TypeMeta,
+				ObjectMeta: fooGPGKeyMeta.ObjectMeta,
+				Spec: GPGKeySpec{
+					Passphrase: correctPassword0,
+					Generate:   &GPGKeyGenerateSpec{UID: "foo <foo@example.com>"},
+				},
+			}
+			err = k8sClient.Create(ctx, fooGPGKeyObj)
+			Expect(err).NotTo(BeNil())
+			Expect(string(errors.ReasonForError(err))).Should(Equal(lang.ErrGPGKeySpecGenerateWithKey))
+		})
+
+		It("Should fail if uid spans multiple lines", func() {
+			By("Creating a generated GPGKey with a newline in its uid")
+			fooGPGKeyObj := &GPGKey{
+				TypeMeta:   fooGPGKeyMeta.TypeMeta,
+				ObjectMeta: fooGPGKeyMeta.ObjectMeta,
+				Spec: GPGKeySpec{
+					Generate: &GPGKeyGenerateSpec{UID: "foo\nPassphrase: bar"},
+				},
+			}
+			err = k8sClient.Create(ctx, fooGPGKeyObj)
+			Expect(err).NotTo(BeNil())
+			Expect(string(errors.ReasonForError(err))).Should(Equal(lang.ErrGPGKeySpecGenerateUID))
+		})
+
+		It("Should reject an expiry which isn't a gpg Expire-Date", func() {
+			By("Validating a generated GPGKey with a newline in its expiry")
+			fooGPGKeyObj := &GPGKey{
+				TypeMeta:   fooGPGKeyMeta.TypeMeta,
+				ObjectMeta: fooGPGKeyMeta.ObjectMeta,
+				Spec: GPGKeySpec{
+					Generate: &GPGKeyGenerateSpec{UID: "foo <foo@example.com>", Expiry: "1y\n%no-protection"},
+				},
+			}
+			Expect(fooGPGKeyObj.validateGenerate()).To(MatchError(lang.ErrGPGKeySpecGenerateExpiry))
+
+			fooGPGKeyObj.Spec.Generate.Expiry = "2030-01-31"
+			Expect(fooGPGKeyObj.validateGenerate()).To(Succeed())
+		})
+
+		It("Should create without private key and passphrase", func() {
+			By("Creating a generated GPGKey with only a uid")
+			fooGPGKeyObj := &GPGKey{
+				TypeMeta:   fooGPGKeyMeta.TypeMeta,
+				ObjectMeta: fooGPGKeyMeta.ObjectMeta,
+				Spec: GPGKeySpec{
+					Generate: &GPGKeyGenerateSpec{UID: "foo <foo@example.com>"},
+				},
+			}
+			err = k8sClient.Create(ctx, fooGPGKeyObj)
+			Expect(err).To(BeNil())
+		})
+
+		It("Should fail if the allowed secrets name pattern is malformed", func() {
+			By("Creating a generated GPGKey with an unterminated name pattern")
+			fooGPGKeyObj := &GPGKey{
+				TypeMeta:   fooGPGKeyMeta.TypeMeta,
+				ObjectMeta: fooGPGKeyMeta.ObjectMeta,
+				Spec: GPGKeySpec{
+					Generate:       &GPGKeyGenerateSpec{UID: "foo <foo@example.com>"},
+					AllowedSecrets: &GPGKeyAllowedSecrets{NamePattern: "payments-["},
+				},
+			}
+			err = k8sClient.Create(ctx, fooGPGKeyObj)
+			Expect(err).NotTo(BeNil())
+			Expect(string(errors.ReasonForError(err))).Should(Equal(lang.ErrGPGKeySpecAllowedSecretsInvalid))
+		})
+	})
+
 	Context("When creating a GPGKey", func() {
 		It("Should fail if armoredPrivateKey is not ok", func() {
 			By("Creating a GPGKey with empty Private Key")
@@ -0,0 +1,96 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GPGKeyGrantSpec defines which namespaces may reference the GPGKeys of the
+// namespace the grant lives in
+type GPGKeyGrantSpec struct {
+	// From are the namespaces whose SopsSecrets may use the GPGKeys
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	From []GPGKeyGrantFrom `json:"from"`
+
+	// To are the GPGKeys that may be used, every GPGKey of the namespace when empty
+	// +kubebuilder:validation:Optional
+	To []GPGKeyGrantTo `json:"to,omitempty"`
+}
+
+// GPGKeyGrantFrom is a namespace allowed to reference GPGKeys
+type GPGKeyGrantFrom struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+}
+
+// GPGKeyGrantTo is a GPGKey which may be referenced
+type GPGKeyGrantTo struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// Permits reports whether SopsSecrets in fromNamespace may use the GPGKey keyName
+func (g *GPGKeyGrant) Permits(fromNamespace, keyName string) bool {
+	namespaceAllowed := false
+	for _, from := range g.Spec.From {
+		if from.Namespace == fromNamespace {
+			namespaceAllowed = true
+			break
+		}
+	}
+	if !namespaceAllowed {
+		return false
+	}
+	if len(g.Spec.To) == 0 {
+		return true
+	}
+	for _, to := range g.Spec.To {
+		if to.Name == keyName {
+			return true
+		}
+	}
+	return false
+}
+
+//+kubebuilder:object:root=true
+
+// GPGKeyGrant is the Schema for the gpgkeygrants API
+//+kubebuilder:resource:scope=Namespaced
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type GPGKeyGrant struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec GPGKeyGrantSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// GPGKeyGrantList contains a list of GPGKeyGrant
+type GPGKeyGrantList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GPGKeyGrant `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&GPGKeyGrant{}, &GPGKeyGrantList{})
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// KeyRotationFinalizer is set on the old GPGKey of a KeyRotation, so it
+	// stays usable until every SopsSecret is re-encrypted for the new key.
+	// It is also set on the KeyRotation itself to release the old GPGKey.
+	KeyRotationFinalizer = "gitopssecret.snappcloud.io/key-rotation"
+
+	// KeyRotationPhasePending when the fingerprints of the keys are not known yet
+	KeyRotationPhasePending = "Pending"
+	// KeyRotationPhaseInProgress when some SopsSecrets, SopsFiles or SopsManifests still only decrypt with the old key
+	KeyRotationPhaseInProgress = "InProgress"
+	// KeyRotationPhaseCompleted when no object depends on the old key anymore
+	KeyRotationPhaseCompleted = "Completed"
+)
+
+// KeyRotationSpec defines the desired state of KeyRotation
+type KeyRotationSpec struct {
+	// OldKeyRef is the name of the GPGKey being retired
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	OldKeyRef string `json:"oldKeyRef"`
+
+	// NewKeyRef is the name of the GPGKey replacing the old one
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	NewKeyRef string `json:"newKeyRef"`
+}
+
+// KeyRotationStatus defines the observed state of KeyRotation
+type KeyRotationStatus struct {
+	// +kubebuilder:validation:Optional
+	Phase string `json:"phase,omitempty"`
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitempty"`
+
+	// OldFingerprint is the fingerprint of the old GPGKey
+	// +kubebuilder:validation:Optional
+	OldFingerprint string `json:"oldFingerprint,omitempty"`
+	// NewFingerprint is the fingerprint of the new GPGKey
+	// +kubebuilder:validation:Optional
+	NewFingerprint string `json:"newFingerprint,omitempty"`
+
+	// PendingSopsSecrets are the SopsSecrets referencing the old key which
+	// are encrypted for it but not for the new one yet, prefixed with their
+	// namespace when it isn't the one of the KeyRotation
+	// +kubebuilder:validation:Optional
+	PendingSopsSecrets []string `json:"pendingSopsSecrets,omitempty"`
+	// PendingSopsFiles are the pending SopsFiles, named the same way
+	// +kubebuilder:validation:Optional
+	PendingSopsFiles []string `json:"pendingSopsFiles,omitempty"`
+	// PendingSopsManifests are the pending SopsManifests, named the same way
+	// +kubebuilder:validation:Optional
+	PendingSopsManifests []string `json:"pendingSopsManifests,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// KeyRotation is the Schema for the keyrotations API
+//+kubebuilder:printcolumn:name="Old",type=string,JSONPath=`.spec.oldKeyRef`
+//+kubebuilder:printcolumn:name="New",type=string,JSONPath=`.spec.newKeyRef`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type KeyRotation struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   KeyRotationSpec   `json:"spec,omitempty"`
+	Status KeyRotationStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// KeyRotationList contains a list of KeyRotation
+type KeyRotationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []KeyRotation `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&KeyRotation{}, &KeyRotationList{})
+}
@@ -0,0 +1,113 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+// requiredSecretKeys are the keys the apiserver requires in the secrets of
+// the built-in types, basic-auth being checked on its own
+var requiredSecretKeys = map[corev1.SecretType][]string{
+	corev1.SecretTypeDockerConfigJson: {corev1.DockerConfigJsonKey},
+	corev1.SecretTypeDockercfg:        {corev1.DockerConfigKey},
+	corev1.SecretTypeTLS:              {corev1.TLSCertKey, corev1.TLSPrivateKeyKey},
+	corev1.SecretTypeSSHAuth:          {corev1.SSHAuthPrivateKey},
+	corev1.SecretTypeBootstrapToken:   {"token-id", "token-secret"},
+}
+
+// DockerConfigHelper is the registry credential of a dockerconfigjson secret
+type DockerConfigHelper struct {
+	// Registry host, e.g. registry.example.com:5000
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Registry string `json:"registry"`
+	// +kubebuilder:validation:Required
+	Username string `json:"username"`
+	// +kubebuilder:validation:Required
+	Password string `json:"password"`
+	// +kubebuilder:validation:Optional
+	Email string `json:"email,omitempty"`
+}
+
+// TLSHelper is the certificate and key of a tls secret, PEM encoded
+type TLSHelper struct {
+	// Certificate is written to tls.crt
+	// +kubebuilder:validation:Required
+	Certificate string `json:"certificate"`
+	// Key is written to tls.key
+	// +kubebuilder:validation:Required
+	Key string `json:"key"`
+	// CA is written to ca.crt when set
+	// +kubebuilder:validation:Optional
+	CA string `json:"ca,omitempty"`
+}
+
+// BasicAuthHelper is the credential of a basic-auth secret
+type BasicAuthHelper struct {
+	// +kubebuilder:validation:Required
+	Username string `json:"username"`
+	// +kubebuilder:validation:Required
+	Password string `json:"password"`
+}
+
+// helperTypes returns the secret types of the typed helpers which are set
+func (s *SopsSecretSpec) helperTypes() []corev1.SecretType {
+	var types []corev1.SecretType
+	if s.DockerConfig != nil {
+		types = append(types, corev1.SecretTypeDockerConfigJson)
+	}
+	if s.TLS != nil {
+		types = append(types, corev1.SecretTypeTLS)
+	}
+	if s.BasicAuth != nil {
+		types = append(types, corev1.SecretTypeBasicAuth)
+	}
+	return types
+}
+
+// HelperKeys returns the child secret keys the typed helpers expand into
+func (s *SopsSecretSpec) HelperKeys() []string {
+	var keys []string
+	if s.DockerConfig != nil {
+		keys = append(keys, corev1.DockerConfigJsonKey)
+	}
+	if s.TLS != nil {
+		keys = append(keys, corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+		if s.TLS.CA != "" {
+			keys = append(keys, corev1.ServiceAccountRootCAKey)
+		}
+	}
+	if s.BasicAuth != nil {
+		keys = append(keys, corev1.BasicAuthUsernameKey, corev1.BasicAuthPasswordKey)
+	}
+	return keys
+}
+
+// SecretType returns the type of the child secret: spec.type, else the type
+// of the typed helper, else Opaque
+func (s *SopsSecretSpec) SecretType() string {
+	if s.Type != "" {
+		return s.Type
+	}
+	if types := s.helperTypes(); len(types) > 0 {
+		return string(types[0])
+	}
+	return string(corev1.SecretTypeOpaque)
+}
+
+// MissingSecretKeys returns the keys required by the built-in secret type
+// which are not in keys
+func MissingSecretKeys(secretType string, keys map[string]bool) []string {
+	if corev1.SecretType(secretType) == corev1.SecretTypeBasicAuth {
+		if !keys[corev1.BasicAuthUsernameKey] && !keys[corev1.BasicAuthPasswordKey] {
+			return []string{corev1.BasicAuthUsernameKey + " or " + corev1.BasicAuthPasswordKey}
+		}
+		return nil
+	}
+	var missing []string
+	for _, key := range requiredSecretKeys[corev1.SecretType(secretType)] {
+		if !keys[key] {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
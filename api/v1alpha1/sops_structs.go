@@ -1,5 +1,12 @@
 package v1alpha1
 
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
 // KmsDataItem defines AWS KMS specific encryption details
 type KmsDataItem struct {
 	// Arn - KMS key ARN to use
@@ -81,6 +88,34 @@ type GcpKmsDataItem struct {
 	CreationDate string `json:"created_at,omitempty"`
 }
 
+// SopsKeyGroup defines the master keys of a sops key group, any of which
+// can decrypt the part of the data key held by the group
+type SopsKeyGroup struct {
+	// Aws KMS configuration
+	//+optional
+	AwsKms []KmsDataItem `json:"kms,omitempty"`
+
+	// PGP configuration
+	//+optional
+	Pgp []PgpDataItem `json:"pgp,omitempty"`
+
+	// Azure KMS configuration
+	//+optional
+	AzureKms []AzureKmsItem `json:"azure_kv,omitempty"`
+
+	// Hashicorp Vault KMS configurarion
+	//+optional
+	HcVault []HcVaultItem `json:"hc_vault,omitempty"`
+
+	// Gcp KMS configuration
+	//+optional
+	GcpKms []GcpKmsDataItem `json:"gcp_kms,omitempty"`
+
+	// Age configuration
+	//+optional
+	Age []AgeItem `json:"age,omitempty"`
+}
+
 // SopsMetadata defines the encryption details
 type SopsMetadata struct {
 	// Aws KMS configuration
@@ -107,6 +142,14 @@ type SopsMetadata struct {
 	//+optional
 	Age []AgeItem `json:"age,omitempty"`
 
+	// KeyGroups replace the lists above when the data key is split with shamir
+	//+optional
+	KeyGroups []SopsKeyGroup `json:"key_groups,omitempty"`
+
+	// ShamirThreshold is the number of key groups needed to recover the data key
+	//+optional
+	ShamirThreshold int `json:"shamir_threshold,omitempty"`
+
 	// Mac - sops setting
 	//+optional
 	Mac string `json:"mac,omitempty"`
@@ -127,4 +170,70 @@ type SopsMetadata struct {
 	// This opstion should be used with more care, as it can make resource unapplicable to the cluster.
 	//+optional
 	EncryptedRegex string `json:"encrypted_regex,omitempty"`
+
+	// Raw is the sops metadata as it was decoded, including the fields
+	// unknown to this struct such as mac_only_encrypted.
+	// It is marshaled instead of the fields above while they match it.
+	Raw []byte `json:"-"`
+}
+
+// Encrypted tells whether the object holding the metadata was encrypted by sops
+func (m *SopsMetadata) Encrypted() bool {
+	return m.LastModified != "" || m.Mac != ""
+}
+
+// PgpKeys returns the pgp keys of the metadata, including the ones of its key groups
+func (m *SopsMetadata) PgpKeys() []PgpDataItem {
+	pgpKeys := append([]PgpDataItem(nil), m.Pgp...)
+	for _, group := range m.KeyGroups {
+		pgpKeys = append(pgpKeys, group.Pgp...)
+	}
+	return pgpKeys
+}
+
+// UnmarshalJSON decodes the known fields and keeps the original document in Raw
+func (m *SopsMetadata) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil
+	}
+	type sopsMetadata SopsMetadata
+	if err := json.Unmarshal(data, (*sopsMetadata)(m)); err != nil {
+		return err
+	}
+	m.Raw = append([]byte(nil), data...)
+	return nil
+}
+
+// MarshalJSON returns Raw while the fields above still match it, so no sops
+// field is lost in a round trip. Once they are changed, they are written over
+// Raw, keeping only the fields unknown to this struct from it.
+func (m SopsMetadata) MarshalJSON() ([]byte, error) {
+	type sopsMetadata SopsMetadata
+	typed := sopsMetadata(m)
+	typed.Raw = nil
+	if len(m.Raw) == 0 {
+		return json.Marshal(typed)
+	}
+
+	original := sopsMetadata{}
+	if err := json.Unmarshal(m.Raw, &original); err == nil && reflect.DeepEqual(original, typed) {
+		return m.Raw, nil
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(m.Raw, &fields); err != nil {
+		return json.Marshal(typed)
+	}
+	metadataType := reflect.TypeOf(typed)
+	for i := 0; i < metadataType.NumField(); i++ {
+		delete(fields, strings.Split(metadataType.Field(i).Tag.Get("json"), ",")[0])
+	}
+	typedJSON, err := json.Marshal(typed)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(typedJSON, &fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
 }
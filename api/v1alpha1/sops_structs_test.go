@@ -0,0 +1,48 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SopsMetadata", func() {
+	It("Should keep the sops fields it doesn't know in a round trip", func() {
+		original := `{"key_groups":[{"pgp":[{"enc":"enc","fp":"FP"}]}],"lastmodified":"2022-08-03T20:45:40Z","mac_only_encrypted":true,"shamir_threshold":1}`
+
+		metadata := &SopsMetadata{}
+		Expect(json.Unmarshal([]byte(original), metadata)).To(Succeed())
+		Expect(metadata.LastModified).To(Equal("2022-08-03T20:45:40Z"))
+
+		marshaled, err := json.Marshal(metadata.DeepCopy())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(marshaled).To(MatchJSON(original))
+	})
+
+	It("Should marshal the fields changed after decoding over the ones it doesn't know", func() {
+		original := `{"lastmodified":"2022-08-03T20:45:40Z","mac":"old","mac_only_encrypted":true,"version":"3.7.2"}`
+
+		metadata := &SopsMetadata{}
+		Expect(json.Unmarshal([]byte(original), metadata)).To(Succeed())
+		metadata.Mac = "new"
+		metadata.Version = ""
+
+		marshaled, err := json.Marshal(metadata)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(marshaled).To(MatchJSON(`{"lastmodified":"2022-08-03T20:45:40Z","mac":"new","mac_only_encrypted":true}`))
+	})
+
+	It("Should list the pgp keys of the key groups", func() {
+		metadata := &SopsMetadata{}
+		Expect(json.Unmarshal([]byte(`{"shamir_threshold":2,"key_groups":[{"pgp":[{"fp":"A"}]},{"pgp":[{"fp":"B"}]}]}`), metadata)).To(Succeed())
+		Expect(metadata.ShamirThreshold).To(Equal(2))
+		Expect(metadata.PgpKeys()).To(Equal([]PgpDataItem{{FingerPrint: "A"}, {FingerPrint: "B"}}))
+	})
+
+	It("Should marshal the known fields when built in code", func() {
+		marshaled, err := json.Marshal(SopsMetadata{Version: "3.7.3"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(marshaled).To(MatchJSON(`{"version":"3.7.3"}`))
+	})
+})
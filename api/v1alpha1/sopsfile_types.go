@@ -0,0 +1,158 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SopsFileFinalizer is set on SopsFiles whose target secret has to be
+// orphaned instead of garbage collected.
+const SopsFileFinalizer = "gitopssecret.snappcloud.io/sopsfile"
+
+// SopsFileSpec defines the desired state of SopsFile
+type SopsFileSpec struct {
+	// Format of the encrypted file
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=yaml;json;dotenv;binary
+	Format string `json:"format"`
+
+	// Data is the whole file as written by sops, e.g. secrets.enc.yaml
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Data string `json:"data"`
+
+	// GPGKeyRefs are the GPGKeys tried in order to decrypt the file
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	GPGKeyRefs []GPGKeyReference `json:"gpgKeyRefs"`
+
+	// Target is the secret the decrypted file is written to
+	// +kubebuilder:validation:Optional
+	Target SopsFileTarget `json:"target,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// DeletionPolicy decides what happens to the target secret when the
+	// SopsFile is deleted, or when target.name changes. Orphan strips its
+	// owner reference and managed annotation and leaves it in place.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +kubebuilder:default=Delete
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+
+	// AdoptionPolicy decides when an existing target secret not created by
+	// this SopsFile is taken over, its data is saved to a backup secret named
+	// <name>-backup-<uid prefix> first
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Never;IfAnnotated;Always
+	// +kubebuilder:default=IfAnnotated
+	AdoptionPolicy string `json:"adoptionPolicy,omitempty"`
+}
+
+// SopsFileTarget defines how the decrypted file is written to a secret
+type SopsFileTarget struct {
+	// Name of the secret, the name of the SopsFile when empty
+	// +kubebuilder:validation:Optional
+	Name string `json:"name,omitempty"`
+
+	// Key of the secret holding the whole decrypted file, the name of the
+	// SopsFile when empty
+	// +kubebuilder:validation:Optional
+	Key string `json:"key,omitempty"`
+
+	// Explode writes every top-level key of the file to a secret key of its
+	// own instead, nested values keep the format of the file. Binary files
+	// can't be exploded.
+	// +kubebuilder:validation:Optional
+	Explode bool `json:"explode,omitempty"`
+
+	// Type of the secret, Opaque when empty
+	// +kubebuilder:validation:Optional
+	Type string `json:"type,omitempty"`
+}
+
+// SopsFileStatus defines the observed state of SopsFile
+type SopsFileStatus struct {
+	// +kubebuilder:validation:Optional
+	Health string `json:"health,omitempty"`
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitempty"`
+	// GPGKeyRef is the GPGKey which last decrypted the file, or the comma
+	// separated GPGKeys which together recovered a shamir split data key
+	// +kubebuilder:validation:Optional
+	GPGKeyRef string `json:"gpgKeyRef,omitempty"`
+	// DataHash is the sha256 of the decrypted content of the target secret
+	// +kubebuilder:validation:Optional
+	DataHash string `json:"dataHash,omitempty"`
+	// TargetName is the secret the file was last written to
+	// +kubebuilder:validation:Optional
+	TargetName string `json:"targetName,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// SopsFile is the Schema for the sopsfiles API
+//+kubebuilder:printcolumn:name="Format",type=string,JSONPath=`.spec.format`
+//+kubebuilder:printcolumn:name="Health",type=string,JSONPath=`.status.health`
+//+kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
+//+kubebuilder:printcolumn:name="GPGKey",type=string,JSONPath=`.status.gpgKeyRef`,priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type SopsFile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SopsFileSpec   `json:"spec,omitempty"`
+	Status SopsFileStatus `json:"status,omitempty"`
+}
+
+// TargetSecretName returns the name of the secret the file is written to
+func (f *SopsFile) TargetSecretName() string {
+	if f.Spec.Target.Name != "" {
+		return f.Spec.Target.Name
+	}
+	return f.Name
+}
+
+// TargetKey returns the secret key holding the whole decrypted file
+func (f *SopsFile) TargetKey() string {
+	if f.Spec.Target.Key != "" {
+		return f.Spec.Target.Key
+	}
+	return f.Name
+}
+
+// SetHealth records the health of the SopsFile in its status
+func (f *SopsFile) SetHealth(health, message string) {
+	f.Status.Health = health
+	f.Status.Message = message
+}
+
+//+kubebuilder:object:root=true
+
+// SopsFileList contains a list of SopsFile
+type SopsFileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SopsFile `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SopsFile{}, &SopsFileList{})
+}
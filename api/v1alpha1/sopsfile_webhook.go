@@ -0,0 +1,99 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"github.com/snapp-incubator/sops-operator/lang"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// log is for logging in this package.
+var sopsfilelog = logf.Log.WithName("sopsfile-resource")
+
+// sopsfileReader looks up the GPGKeyGrants of cross namespace references,
+// the check is skipped until the webhook is set up with a manager
+var sopsfileReader client.Reader
+
+func (r *SopsFile) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	sopsfileReader = mgr.GetAPIReader()
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/validate-gitopssecret-snappcloud-io-v1alpha1-sopsfile,mutating=false,failurePolicy=fail,sideEffects=None,groups=gitopssecret.snappcloud.io,resources=sopsfiles,verbs=create;update,versions=v1alpha1,name=vsopsfile.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &SopsFile{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *SopsFile) ValidateCreate() error {
+	sopsfilelog.Info("validate create", "name", r.Name)
+	return r.ValidateSopsFile()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *SopsFile) ValidateUpdate(old runtime.Object) error {
+	sopsfilelog.Info("validate update", "name", r.Name)
+	return r.ValidateSopsFile()
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *SopsFile) ValidateDelete() error {
+	sopsfilelog.Info("validate delete", "name", r.Name)
+	return nil
+}
+
+func (r *SopsFile) ValidateSopsFile() error {
+	if err := r.validateTarget(); err != nil {
+		return err
+	}
+	if sopsfileReader != nil {
+		for _, ref := range r.Spec.GPGKeyRefs {
+			granted, err := IsGPGKeyReferenceGranted(context.Background(), sopsfileReader, r.Namespace, ref)
+			if err != nil {
+				return err
+			}
+			if !granted {
+				return fmt.Errorf(lang.ErrSopsSecretSpecGPGKeyRefNotGranted)
+			}
+		}
+	}
+	return nil
+}
+
+// validateTarget checks the target secret can be written: its name and key
+// are valid, and a binary file, which has no keys of its own, isn't exploded
+func (r *SopsFile) validateTarget() error {
+	target := r.Spec.Target
+	if target.Name != "" && len(validation.IsDNS1123Subdomain(target.Name)) > 0 {
+		return fmt.Errorf(lang.ErrSopsFileSpecTargetInvalid)
+	}
+	if target.Key != "" && len(validation.IsConfigMapKey(target.Key)) > 0 {
+		return fmt.Errorf(lang.ErrSopsFileSpecTargetInvalid)
+	}
+	if target.Explode && r.Spec.Format == "binary" {
+		return fmt.Errorf(lang.ErrSopsFileSpecExplodeBinary)
+	}
+	return nil
+}
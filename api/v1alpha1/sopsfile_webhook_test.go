@@ -0,0 +1,94 @@
+package v1alpha1
+
+import (
+	"context"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/snapp-incubator/sops-operator/lang"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("SopsFile webhook", func() {
+
+	const (
+		fooSopsFileName          = "foo-sopsfile"
+		fooSopsFileNameSpace     = "default"
+		fooSopsFileGPGKeyRefName = "foo-gpgkey"
+		fooSopsFileData          = "ENC[AES256_GCM,data:abc,type:str]"
+	)
+	var (
+		err error
+		ctx = context.Background()
+	)
+
+	fooSopsFileMeta := &SopsFile{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "gitopssecret.snappcloud.io/v1alpha1",
+			Kind:       "SopsFile",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fooSopsFileName,
+			Namespace: fooSopsFileNameSpace,
+		},
+	}
+
+	AfterEach(func() {
+		err = k8sClient.Delete(ctx, fooSopsFileMeta)
+		if err != nil {
+			Expect(errors.IsNotFound(err)).Should(BeTrue())
+		}
+	})
+
+	Context("When creating a SopsFile", func() {
+		It("Should fail if target.key is not a valid secret key", func() {
+			By("Creating a SopsFile writing to the key my/key")
+			fooSopsFileObj := &SopsFile{
+				TypeMeta:   fooSopsFileMeta.TypeMeta,
+				ObjectMeta: fooSopsFileMeta.ObjectMeta,
+				Spec: SopsFileSpec{
+					Format:     "yaml",
+					Data:       fooSopsFileData,
+					GPGKeyRefs: []GPGKeyReference{{Name: fooSopsFileGPGKeyRefName}},
+					Target:     SopsFileTarget{Key: "my/key"},
+				},
+			}
+			err = k8sClient.Create(ctx, fooSopsFileObj)
+			Expect(err).NotTo(BeNil())
+			Expect(string(errors.ReasonForError(err))).Should(Equal(lang.ErrSopsFileSpecTargetInvalid))
+		})
+
+		It("Should fail if a binary file is exploded", func() {
+			By("Creating a binary SopsFile with target.explode")
+			barSopsFileObj := &SopsFile{
+				TypeMeta:   fooSopsFileMeta.TypeMeta,
+				ObjectMeta: fooSopsFileMeta.ObjectMeta,
+				Spec: SopsFileSpec{
+					Format:     "binary",
+					Data:       fooSopsFileData,
+					GPGKeyRefs: []GPGKeyReference{{Name: fooSopsFileGPGKeyRefName}},
+					Target:     SopsFileTarget{Explode: true},
+				},
+			}
+			err = k8sClient.Create(ctx, barSopsFileObj)
+			Expect(err).NotTo(BeNil())
+			Expect(string(errors.ReasonForError(err))).Should(Equal(lang.ErrSopsFileSpecExplodeBinary))
+		})
+
+		It("Should create a binary file written to a valid key", func() {
+			By("Creating a binary SopsFile writing to the key tls.p12")
+			bazSopsFileObj := &SopsFile{
+				TypeMeta:   fooSopsFileMeta.TypeMeta,
+				ObjectMeta: fooSopsFileMeta.ObjectMeta,
+				Spec: SopsFileSpec{
+					Format:     "binary",
+					Data:       fooSopsFileData,
+					GPGKeyRefs: []GPGKeyReference{{Name: fooSopsFileGPGKeyRefName}},
+					Target:     SopsFileTarget{Key: "tls.p12"},
+				},
+			}
+			err = k8sClient.Create(ctx, bazSopsFileObj)
+			Expect(err).To(BeNil())
+		})
+	})
+})
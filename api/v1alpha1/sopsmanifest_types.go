@@ -0,0 +1,128 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SopsManifestFinalizer is set on SopsManifests whose applied object has to
+// be orphaned instead of garbage collected.
+const SopsManifestFinalizer = "gitopssecret.snappcloud.io/sopsmanifest"
+
+// SopsManifestSpec defines the desired state of SopsManifest
+type SopsManifestSpec struct {
+	// Format of the encrypted manifest
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=yaml;json
+	// +kubebuilder:default=yaml
+	Format string `json:"format,omitempty"`
+
+	// Data is the manifest of a single namespaced object as encrypted by
+	// sops, e.g. with --encrypted-regex '^(data|stringData|spec)$'. Its kind
+	// has to be allowed by the --sopsmanifest-allowed-kinds of the operator.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Data string `json:"data"`
+
+	// GPGKeyRefs are the GPGKeys tried in order to decrypt the manifest
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	GPGKeyRefs []GPGKeyReference `json:"gpgKeyRefs"`
+
+	// +kubebuilder:validation:Optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// DeletionPolicy decides what happens to the applied object when the
+	// SopsManifest is deleted, or when the manifest is renamed or changes
+	// kind. Orphan strips its owner reference and managed annotation and
+	// leaves it in place.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +kubebuilder:default=Delete
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+
+	// AdoptionPolicy decides when an existing object not created by this
+	// SopsManifest is taken over, its manifest is saved to a backup secret
+	// named <name>-backup-<uid prefix> first
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Never;IfAnnotated;Always
+	// +kubebuilder:default=IfAnnotated
+	AdoptionPolicy string `json:"adoptionPolicy,omitempty"`
+}
+
+// ManifestObjectReference points to the object applied from a SopsManifest,
+// in the namespace of the SopsManifest
+type ManifestObjectReference struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+}
+
+// SopsManifestStatus defines the observed state of SopsManifest
+type SopsManifestStatus struct {
+	// +kubebuilder:validation:Optional
+	Health string `json:"health,omitempty"`
+	// +kubebuilder:validation:Optional
+	Message string `json:"message,omitempty"`
+	// GPGKeyRef is the GPGKey which last decrypted the manifest, or the comma
+	// separated GPGKeys which together recovered a shamir split data key
+	// +kubebuilder:validation:Optional
+	GPGKeyRef string `json:"gpgKeyRef,omitempty"`
+	// DataHash is the sha256 of the decrypted manifest last applied
+	// +kubebuilder:validation:Optional
+	DataHash string `json:"dataHash,omitempty"`
+	// Object is the object last applied
+	// +kubebuilder:validation:Optional
+	Object *ManifestObjectReference `json:"object,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// SopsManifest is the Schema for the sopsmanifests API
+//+kubebuilder:printcolumn:name="Kind",type=string,JSONPath=`.status.object.kind`
+//+kubebuilder:printcolumn:name="Object",type=string,JSONPath=`.status.object.name`
+//+kubebuilder:printcolumn:name="Health",type=string,JSONPath=`.status.health`
+//+kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type SopsManifest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SopsManifestSpec   `json:"spec,omitempty"`
+	Status SopsManifestStatus `json:"status,omitempty"`
+}
+
+// SetHealth records the health of the SopsManifest in its status
+func (m *SopsManifest) SetHealth(health, message string) {
+	m.Status.Health = health
+	m.Status.Message = message
+}
+
+//+kubebuilder:object:root=true
+
+// SopsManifestList contains a list of SopsManifest
+type SopsManifestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SopsManifest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SopsManifest{}, &SopsManifestList{})
+}
@@ -17,6 +17,12 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -24,18 +30,314 @@ const (
 	// SopsSecretManagedAnnotation is the name for the annotation for
 	// flagging the existing secret be managed by SopsSecret controller.
 	SopsSecretManagedAnnotation = "gitops-controller.snappcloud.io/managed"
+
+	// SopsSecretFinalizer is set on SopsSecrets whose child secret has to be
+	// orphaned instead of garbage collected.
+	SopsSecretFinalizer = "gitopssecret.snappcloud.io/sopssecret"
+
+	// DeletionPolicyDelete lets the child secret be garbage collected with the SopsSecret
+	DeletionPolicyDelete = "Delete"
+	// DeletionPolicyOrphan keeps the child secret once the SopsSecret is deleted
+	DeletionPolicyOrphan = "Orphan"
+
+	// AdoptionPolicyNever never takes over an existing secret
+	AdoptionPolicyNever = "Never"
+	// AdoptionPolicyIfAnnotated takes over existing secrets flagged with SopsSecretManagedAnnotation
+	AdoptionPolicyIfAnnotated = "IfAnnotated"
+	// AdoptionPolicyAlways takes over existing secrets which have no other controller
+	AdoptionPolicyAlways = "Always"
+
+	// SopsSecretBackupOfAnnotation is set on the backup of an adopted secret,
+	// with the name and type of the original secret as its value, or of an
+	// object adopted by a SopsManifest, with its name and kind.
+	SopsSecretBackupOfAnnotation = "gitopssecret.snappcloud.io/backup-of"
+
+	// SopsSecretBackedUpAtAnnotation is set on the backup of an adopted
+	// object, with the RFC 3339 time of the backup.
+	SopsSecretBackedUpAtAnnotation = "gitopssecret.snappcloud.io/backed-up-at"
+
+	// SopsSecretOwnerUIDAnnotation is set on every object written by a
+	// SopsSecret, SopsFile or SopsManifest with the uid of its owner, so an
+	// object whose owner reference was removed by hand is only taken back by
+	// that same owner, whatever its adoptionPolicy.
+	SopsSecretOwnerUIDAnnotation = "gitopssecret.snappcloud.io/owner-uid"
+
+	// MergeStrategyReplace makes the child secret an exact copy of the SopsSecret
+	MergeStrategyReplace = "Replace"
+	// MergeStrategyMerge only manages the keys of the SopsSecret in the child secret
+	MergeStrategyMerge = "Merge"
+
+	// SopsSecretChecksumAnnotation is patched onto the pod templates of the
+	// workloads to roll out, with the checksum of the child secret data.
+	SopsSecretChecksumAnnotation = "gitopssecret.snappcloud.io/secret-checksum"
+
+	// SopsSecretDataHashAnnotation is set on the child secret with the
+	// sha256 of the decrypted content, same as status.dataHash
+	SopsSecretDataHashAnnotation = "gitopssecret.snappcloud.io/data-hash"
+
+	// SopsSecretSkipMACVerificationAnnotation set to "true" on a SopsSecret
+	// decrypts it without verifying the sops mac of its document, source,
+	// files and stringData. A SopsSecret encrypted without
+	// mac_only_encrypted needs it to be decrypted at all, as sops otherwise
+	// hashes the metadata rewritten by the apiserver; with it the encrypted
+	// keys are sorted the way the apiserver stores them.
+	SopsSecretSkipMACVerificationAnnotation = "gitopssecret.snappcloud.io/skip-mac-verification"
 )
 
 // SopsSecretSpec defines the desired state of SopsSecret
 type SopsSecretSpec struct {
-	// +kubebuilder:validation:Required
-	StringData map[string]string `json:"stringData,omitempty"`
-	// +kubebuilder:validation:Required
-	GPGKeyRefName string `json:"gpg_key_ref_name"`
+	// StringData values may be strings, numbers or booleans, as typed by sops,
+	// and are written to the child secret in their string form
+	// +kubebuilder:validation:Optional
+	StringData map[string]apiextensionsv1.JSON `json:"stringData,omitempty"`
+	// Files are structured payloads whose leaves are encrypted by sops, each
+	// written to the child secret key of its name. The extension of the name
+	// picks the format: .json, .env (dotenv), .properties, otherwise yaml.
+	// +kubebuilder:validation:Optional
+	Files map[string]apiextensionsv1.JSON `json:"files,omitempty"`
+	// Document is a whole sops encrypted dotenv or ini document, expanded
+	// into one child secret key per entry
+	// +kubebuilder:validation:Optional
+	Document *SopsDocument `json:"document,omitempty"`
+	// SourceRef points to a sops encrypted file stored outside of the
+	// SopsSecret, decrypted with the same GPGKeys
+	// +kubebuilder:validation:Optional
+	SourceRef *SopsSourceReference `json:"sourceRef,omitempty"`
+	// DockerConfig is expanded into the .dockerconfigjson key of a
+	// kubernetes.io/dockerconfigjson secret after decryption
+	// +kubebuilder:validation:Optional
+	DockerConfig *DockerConfigHelper `json:"dockerConfig,omitempty"`
+	// TLS is expanded into the tls.crt, tls.key and ca.crt keys of a
+	// kubernetes.io/tls secret after decryption
+	// +kubebuilder:validation:Optional
+	TLS *TLSHelper `json:"tls,omitempty"`
+	// BasicAuth is expanded into the username and password keys of a
+	// kubernetes.io/basic-auth secret after decryption
+	// +kubebuilder:validation:Optional
+	BasicAuth *BasicAuthHelper `json:"basicAuth,omitempty"`
+	// GPGKeyRefName is the single GPGKey form, kept for compatibility with
+	// existing SopsSecrets. It is always tried first.
+	// +kubebuilder:validation:Optional
+	GPGKeyRefName string `json:"gpg_key_ref_name,omitempty"`
+	// GPGKeyRefs are the GPGKeys tried in order to decrypt the SopsSecret
+	// +kubebuilder:validation:Optional
+	GPGKeyRefs []GPGKeyReference `json:"gpgKeyRefs,omitempty"`
+	// Type of the child secret, the type of the typed helper or Opaque when
+	// empty. The keys required by the built-in types have to be set.
 	// +kubebuilder:validation:Optional
 	Type string `json:"type,omitempty"`
 	// +kubebuilder:validation:Optional
 	Suspend bool `json:"suspend,omitempty"`
+	// DeletionPolicy decides what happens to the child secret when the
+	// SopsSecret is deleted, Orphan strips its owner reference and managed
+	// annotation and leaves it in place
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +kubebuilder:default=Delete
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+	// AdoptionPolicy decides when an existing secret not created by this
+	// SopsSecret is taken over, its contents are saved to a backup secret
+	// named <name>-backup-<uid prefix> first
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Never;IfAnnotated;Always
+	// +kubebuilder:default=IfAnnotated
+	AdoptionPolicy string `json:"adoptionPolicy,omitempty"`
+	// MergeStrategy decides how the child secret is written. Replace
+	// overwrites its data, type, labels and annotations, Merge owns only the
+	// keys, labels and annotations of this SopsSecret through server-side
+	// apply and keeps whatever other managers wrote. Merging into a secret
+	// controlled by another object adds a plain owner reference only.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Replace;Merge
+	// +kubebuilder:default=Replace
+	MergeStrategy string `json:"mergeStrategy,omitempty"`
+	// RolloutOnChange restarts the workloads using the child secret once its
+	// data changes
+	// +kubebuilder:validation:Optional
+	RolloutOnChange *RolloutOnChange `json:"rolloutOnChange,omitempty"`
+	// RefreshInterval re-reconciles the SopsSecret periodically, e.g. 10m,
+	// to correct hand edits of the child secret sooner than --sync-period.
+	// It is also how often an OCI sourceRef is polled for a moved tag.
+	// +kubebuilder:validation:Optional
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+}
+
+// RolloutOnChange lists the workloads to restart when the child secret changes
+type RolloutOnChange struct {
+	// Workloads are restarted regardless of how they use the secret
+	// +kubebuilder:validation:Optional
+	Workloads []WorkloadReference `json:"workloads,omitempty"`
+
+	// AutoDiscover also restarts every Deployment, StatefulSet and DaemonSet
+	// of the namespace which uses the child secret through env, envFrom or volumes
+	// +kubebuilder:validation:Optional
+	AutoDiscover bool `json:"autoDiscover,omitempty"`
+}
+
+// WorkloadReference points to a workload in the namespace of the SopsSecret
+type WorkloadReference struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet;DaemonSet
+	Kind string `json:"kind"`
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// GPGKeyReference points to a GPGKey used to decrypt a SopsSecret
+type GPGKeyReference struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Namespace of the GPGKey, the namespace of the SopsSecret when empty.
+	// A GPGKey in another namespace is only used when a GPGKeyGrant there
+	// permits it.
+	// +kubebuilder:validation:Optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// String returns the name of the GPGKey, prefixed by its namespace when set
+func (r GPGKeyReference) String() string {
+	if r.Namespace == "" {
+		return r.Name
+	}
+	return r.Namespace + "/" + r.Name
+}
+
+// GetGPGKeyRefs returns the GPGKeys to try in order, starting with
+// GPGKeyRefName when it is set
+func (s *SopsSecretSpec) GetGPGKeyRefs() []GPGKeyReference {
+	var refs []GPGKeyReference
+	if s.GPGKeyRefName != "" {
+		refs = append(refs, GPGKeyReference{Name: s.GPGKeyRefName})
+	}
+	for _, ref := range s.GPGKeyRefs {
+		if ref.Namespace != "" || ref.Name != s.GPGKeyRefName {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// StringDataValues returns stringData with every value in its string form
+func (s *SopsSecretSpec) StringDataValues() (map[string]string, error) {
+	values := make(map[string]string, len(s.StringData))
+	for key, value := range s.StringData {
+		stringValue, err := StringDataValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("stringData %s: %w", key, err)
+		}
+		values[key] = stringValue
+	}
+	return values, nil
+}
+
+// StringDataValue returns the string form of a stringData value, strings as
+// they are and numbers or booleans as written in JSON. Maps and lists have
+// no string form.
+func StringDataValue(value apiextensionsv1.JSON) (string, error) {
+	var decoded interface{}
+	decoder := json.NewDecoder(bytes.NewReader(value.Raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return "", err
+	}
+	switch typed := decoded.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return typed, nil
+	case json.Number:
+		return typed.String(), nil
+	case bool:
+		return strconv.FormatBool(typed), nil
+	default:
+		return "", fmt.Errorf("maps and lists can't be stored as a secret value")
+	}
+}
+
+// SopsDocument is a document encrypted by sops as a whole, carrying its own
+// sops metadata, so the SopsSecret around it needs no encryption
+type SopsDocument struct {
+	// Format of the document
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=dotenv;ini
+	Format string `json:"format"`
+
+	// Data is the encrypted document as written by sops
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Data string `json:"data"`
+}
+
+// SopsSourceReference points to a sops encrypted file in exactly one of a
+// ConfigMap, a Secret or an OCI artifact
+type SopsSourceReference struct {
+	// Format of the file
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=yaml;json;dotenv;ini;binary
+	Format string `json:"format"`
+
+	// Key of the child secret receiving the whole decrypted file. Every
+	// top-level key of the file is written to a key of its own when empty,
+	// which binary files don't support.
+	// +kubebuilder:validation:Optional
+	Key string `json:"key,omitempty"`
+
+	// ConfigMap holding the file in one of its keys
+	// +kubebuilder:validation:Optional
+	ConfigMap *SourceKeySelector `json:"configMap,omitempty"`
+
+	// Secret holding the file in one of its keys
+	// +kubebuilder:validation:Optional
+	Secret *SourceKeySelector `json:"secret,omitempty"`
+
+	// OCI artifact holding the file as a layer. Registries aren't watched,
+	// the artifact is pulled again every refreshInterval, or every
+	// --sync-period when unset, and on every change of the SopsSecret.
+	// +kubebuilder:validation:Optional
+	OCI *OCISource `json:"oci,omitempty"`
+}
+
+// SourceKeySelector selects a key of a ConfigMap or Secret in the namespace
+// of the SopsSecret
+type SourceKeySelector struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Key string `json:"key"`
+}
+
+// OCISource points to an artifact of an OCI registry, e.g. one pushed with
+// oras push registry.registry.svc:5000/team/secrets:v1 secrets.enc.yaml.
+// Registries using basic auth or a bearer token service, e.g. Harbor or
+// GHCR, are supported.
+type OCISource struct {
+	// Repository of the artifact, e.g. registry.registry.svc:5000/team/secrets
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Repository string `json:"repository"`
+
+	// Reference is a tag or a sha256 digest, latest when empty
+	// +kubebuilder:validation:Optional
+	Reference string `json:"reference,omitempty"`
+
+	// File is the org.opencontainers.image.title of the layer holding the
+	// file, the artifact must have a single layer when empty
+	// +kubebuilder:validation:Optional
+	File string `json:"file,omitempty"`
+
+	// Insecure pulls over plain http
+	// +kubebuilder:validation:Optional
+	Insecure bool `json:"insecure,omitempty"`
+
+	// PullSecretName is a kubernetes.io/dockerconfigjson Secret in the
+	// namespace of the SopsSecret with the credentials of the registry, also
+	// sent to its token service. Tokens are requested anonymously when empty.
+	// +kubebuilder:validation:Optional
+	PullSecretName string `json:"pullSecretName,omitempty"`
 }
 
 // SopsSecretStatus defines the observed state of SopsSecret
@@ -44,6 +346,27 @@ type SopsSecretStatus struct {
 	// +kubebuilder:validation:Optional
 	Health  string `json:"health"`
 	Message string `json:"message,omitempty"`
+	// GPGKeyRef is the GPGKey which last decrypted the SopsSecret, or the
+	// comma separated GPGKeys which together recovered a shamir split data key
+	// +kubebuilder:validation:Optional
+	GPGKeyRef string `json:"gpgKeyRef,omitempty"`
+	// DataHash is the sha256 of the decrypted content of the child secret
+	// +kubebuilder:validation:Optional
+	DataHash string `json:"dataHash,omitempty"`
+	// LastSyncedAt is when the child secret last got a new DataHash
+	// +kubebuilder:validation:Optional
+	LastSyncedAt *metav1.Time `json:"lastSyncedAt,omitempty"`
+	// SopsLastModified is the sops lastmodified of the synced content
+	// +kubebuilder:validation:Optional
+	SopsLastModified string `json:"sopsLastModified,omitempty"`
+	// SourceRevision is the resourceVersion of the ConfigMap or Secret, or the
+	// manifest digest of the OCI artifact, of spec.sourceRef last synced
+	// +kubebuilder:validation:Optional
+	SourceRevision string `json:"sourceRevision,omitempty"`
+	// PendingRolloutChecksum is the checksum of the child secret data whose
+	// rollout to the workloads of rolloutOnChange hasn't succeeded yet
+	// +kubebuilder:validation:Optional
+	PendingRolloutChecksum string `json:"pendingRolloutChecksum,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -55,6 +378,8 @@ type SopsSecretStatus struct {
 //+kubebuilder:storageversion
 //+kubebuilder:printcolumn:name="Health",type=string,JSONPath=`.status.health`
 //+kubebuilder:printcolumn:name="Message",type=string,JSONPath=`.status.message`
+//+kubebuilder:printcolumn:name="GPGKey",type=string,JSONPath=`.status.gpgKeyRef`,priority=1
+//+kubebuilder:printcolumn:name="Data Hash",type=string,JSONPath=`.status.dataHash`,priority=1
 //+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 type SopsSecret struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -62,7 +387,10 @@ type SopsSecret struct {
 
 	Spec   SopsSecretSpec   `json:"spec,omitempty"`
 	Status SopsSecretStatus `json:"status,omitempty"`
-	Sops   SopsMetadata     `json:"sops,omitempty"`
+
+	// Sops is kept as written by sops, fields unknown to SopsMetadata included
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Sops SopsMetadata `json:"sops,omitempty"`
 }
 
 //+kubebuilder:object:root=true
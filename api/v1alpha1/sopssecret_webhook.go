@@ -17,10 +17,15 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/snapp-incubator/sops-operator/lang"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
@@ -28,7 +33,12 @@ import (
 // log is for logging in this package.
 var sopssecretlog = logf.Log.WithName("sopssecret-resource")
 
+// sopssecretReader looks up the GPGKeyGrants of cross namespace references,
+// the check is skipped until the webhook is set up with a manager
+var sopssecretReader client.Reader
+
 func (r *SopsSecret) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	sopssecretReader = mgr.GetAPIReader()
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
@@ -43,6 +53,9 @@ var _ webhook.Defaulter = &SopsSecret{}
 // Default implements webhook.Defaulter so a webhook will be registered for the type
 func (r *SopsSecret) Default() {
 	sopssecretlog.Info("default", "name", r.Name)
+
+	// fold gpg_key_ref_name into gpgKeyRefs, so both forms read the same
+	r.Spec.GPGKeyRefs = r.Spec.GetGPGKeyRefs()
 }
 
 // TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
@@ -81,11 +94,148 @@ func (r *SopsSecret) ValidateDelete() error {
 }
 
 func (r *SopsSecret) ValidateSopsSecret() error {
-	if r.Spec.GPGKeyRefName == "" {
+	if len(r.Spec.GetGPGKeyRefs()) == 0 {
 		return fmt.Errorf(lang.ErrSopsSecretSpecGPGKeyRefNameEmpty)
 	}
-	if len(r.Spec.StringData) == 0 {
+	if len(r.Spec.StringData) == 0 && len(r.Spec.Files) == 0 && r.Spec.Document == nil && r.Spec.SourceRef == nil &&
+		len(r.Spec.HelperKeys()) == 0 {
 		return fmt.Errorf(lang.ErrSopsSecretSpecNoData)
 	}
+	if _, err := r.Spec.StringDataValues(); err != nil {
+		return fmt.Errorf(lang.ErrSopsSecretSpecStringDataStructured)
+	}
+	if err := r.validateFiles(); err != nil {
+		return err
+	}
+	if err := r.validateSourceRef(); err != nil {
+		return err
+	}
+	if err := r.validateSecretType(); err != nil {
+		return err
+	}
+	if sopssecretReader != nil {
+		for _, ref := range r.Spec.GetGPGKeyRefs() {
+			granted, err := IsGPGKeyReferenceGranted(context.Background(), sopssecretReader, r.Namespace, ref)
+			if err != nil {
+				return err
+			}
+			if !granted {
+				return fmt.Errorf(lang.ErrSopsSecretSpecGPGKeyRefNotGranted)
+			}
+			if err := r.validateGPGKeyAllowsSecret(ref); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateGPGKeyAllowsSecret checks the allowedSecrets of a referenced GPGKey,
+// a GPGKey which doesn't exist yet is checked by the controller later on
+func (r *SopsSecret) validateGPGKeyAllowsSecret(ref GPGKeyReference) error {
+	gpgKey := &GPGKey{}
+	namespacedName := types.NamespacedName{Namespace: r.Namespace, Name: ref.Name}
+	if ref.Namespace != "" {
+		namespacedName.Namespace = ref.Namespace
+	}
+	if err := sopssecretReader.Get(context.Background(), namespacedName, gpgKey); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	allowed, err := gpgKey.Spec.AllowedSecrets.Allows(r)
+	if err != nil || !allowed {
+		return fmt.Errorf(lang.ErrSopsSecretSpecGPGKeyRefNotAllowed)
+	}
+	return nil
+}
+
+// IsGPGKeyReferenceGranted reports whether SopsSecrets in fromNamespace may use
+// the referenced GPGKey, which is always the case within the same namespace
+func IsGPGKeyReferenceGranted(ctx context.Context, c client.Reader, fromNamespace string, ref GPGKeyReference) (bool, error) {
+	if ref.Namespace == "" || ref.Namespace == fromNamespace {
+		return true, nil
+	}
+	grants := &GPGKeyGrantList{}
+	if err := c.List(ctx, grants, client.InNamespace(ref.Namespace)); err != nil {
+		return false, err
+	}
+	for i := range grants.Items {
+		if grants.Items[i].Permits(fromNamespace, ref.Name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// validateSourceRef checks the source is set exactly once and that a binary
+// file, which has no keys of its own, is written to a key
+func (r *SopsSecret) validateSourceRef() error {
+	sourceRef := r.Spec.SourceRef
+	if sourceRef == nil {
+		return nil
+	}
+	sources := 0
+	for _, set := range []bool{sourceRef.ConfigMap != nil, sourceRef.Secret != nil, sourceRef.OCI != nil} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf(lang.ErrSopsSecretSpecSourceRefInvalid)
+	}
+	if sourceRef.Key == "" && sourceRef.Format == "binary" {
+		return fmt.Errorf(lang.ErrSopsSecretSpecSourceRefInvalid)
+	}
+	if sourceRef.Key != "" && len(validation.IsConfigMapKey(sourceRef.Key)) > 0 {
+		return fmt.Errorf(lang.ErrSopsSecretSpecSourceRefInvalid)
+	}
+	return nil
+}
+
+// validateFiles checks every file is a map stored under a free, valid secret key
+func (r *SopsSecret) validateFiles() error {
+	for name, content := range r.Spec.Files {
+		var tree map[string]interface{}
+		if len(validation.IsConfigMapKey(name)) > 0 || json.Unmarshal(content.Raw, &tree) != nil || tree == nil {
+			return fmt.Errorf(lang.ErrSopsSecretSpecFilesInvalid)
+		}
+		if _, ok := r.Spec.StringData[name]; ok {
+			return fmt.Errorf(lang.ErrSopsSecretSpecFilesInvalid)
+		}
+	}
+	return nil
+}
+
+// validateSecretType checks at most one typed helper is set, that it matches
+// spec.type, and that the keys required by the secret type are set. The keys
+// of a document or a sourceRef are only known once decrypted, the controller
+// checks those.
+func (r *SopsSecret) validateSecretType() error {
+	helperTypes := r.Spec.helperTypes()
+	if len(helperTypes) > 1 {
+		return fmt.Errorf(lang.ErrSopsSecretSpecHelpersInvalid)
+	}
+	if len(helperTypes) == 1 && r.Spec.Type != "" && r.Spec.Type != string(helperTypes[0]) {
+		return fmt.Errorf(lang.ErrSopsSecretSpecHelpersInvalid)
+	}
+	if r.Spec.Document != nil || r.Spec.SourceRef != nil {
+		return nil
+	}
+
+	keys := map[string]bool{}
+	for key := range r.Spec.StringData {
+		keys[key] = true
+	}
+	for name := range r.Spec.Files {
+		keys[name] = true
+	}
+	for _, key := range r.Spec.HelperKeys() {
+		if keys[key] {
+			return fmt.Errorf(lang.ErrSopsSecretSpecHelpersInvalid)
+		}
+		keys[key] = true
+	}
+	if len(MissingSecretKeys(r.Spec.SecretType(), keys)) > 0 {
+		return fmt.Errorf(lang.ErrSopsSecretSpecTypeKeysMissing)
+	}
 	return nil
 }
@@ -5,6 +5,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/snapp-incubator/sops-operator/lang"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -22,7 +23,7 @@ var _ = Describe("SopsSecret webhook", func() {
 		err error
 		//sopssecret *SopsSecret
 		ctx                     = context.Background()
-		fooSopsSecretStringData = map[string]string{"fooStringDataKey": "fooStringDataValue"}
+		fooSopsSecretStringData = map[string]apiextensionsv1.JSON{"fooStringDataKey": {Raw: []byte(`"fooStringDataValue"`)}}
 	)
 
 	sopsSecretTypeMeta := metav1.TypeMeta{
@@ -54,7 +55,7 @@ var _ = Describe("SopsSecret webhook", func() {
 				Spec: SopsSecretSpec{
 					Suspend:       false,
 					GPGKeyRefName: fooSopsSecretGPGKeyRefName,
-					StringData:    map[string]string{},
+					StringData:    map[string]apiextensionsv1.JSON{},
 				},
 			}
 			err = k8sClient.Create(ctx, fooSopsSecretObj)
@@ -93,5 +94,135 @@ var _ = Describe("SopsSecret webhook", func() {
 			err = k8sClient.Get(ctx, sopsLookupKey, barSopsSecretObj)
 			Expect(barSopsSecretObj.Spec.Suspend).To(BeFalse())
 		})
+
+		It("Should default gpgKeyRefs from gpg_key_ref_name", func() {
+			By("Creating a SopsSecret with both reference forms")
+			bazSopsSecretObj := &SopsSecret{
+				TypeMeta:   foosopsSecretMeta.TypeMeta,
+				ObjectMeta: foosopsSecretMeta.ObjectMeta,
+				Spec: SopsSecretSpec{
+					GPGKeyRefName: fooSopsSecretGPGKeyRefName,
+					GPGKeyRefs:    []GPGKeyReference{{Name: "bar-gpgkey"}},
+					StringData:    fooSopsSecretStringData,
+				},
+			}
+			err = k8sClient.Create(ctx, bazSopsSecretObj)
+			Expect(err).To(BeNil())
+			sopsLookupKey := types.NamespacedName{Name: bazSopsSecretObj.GetName(), Namespace: bazSopsSecretObj.GetNamespace()}
+			err = k8sClient.Get(ctx, sopsLookupKey, bazSopsSecretObj)
+			Expect(err).To(BeNil())
+			Expect(bazSopsSecretObj.Spec.GPGKeyRefs).To(Equal([]GPGKeyReference{
+				{Name: fooSopsSecretGPGKeyRefName},
+				{Name: "bar-gpgkey"},
+			}))
+		})
+
+		It("Should fail if a GPGKey in another namespace is not granted", func() {
+			By("Creating a SopsSecret referencing a GPGKey in kube-system")
+			quxSopsSecretObj := &SopsSecret{
+				TypeMeta:   foosopsSecretMeta.TypeMeta,
+				ObjectMeta: foosopsSecretMeta.ObjectMeta,
+				Spec: SopsSecretSpec{
+					GPGKeyRefs: []GPGKeyReference{{Name: fooSopsSecretGPGKeyRefName, Namespace: "kube-system"}},
+					StringData: fooSopsSecretStringData,
+				},
+			}
+			err = k8sClient.Create(ctx, quxSopsSecretObj)
+			Expect(err).NotTo(BeNil())
+			Expect(string(errors.ReasonForError(err))).Should(Equal(lang.ErrSopsSecretSpecGPGKeyRefNotGranted))
+		})
+
+		It("Should fail if a stringData value is a map", func() {
+			By("Creating a SopsSecret with a nested stringData value")
+			quuxSopsSecretObj := &SopsSecret{
+				TypeMeta:   foosopsSecretMeta.TypeMeta,
+				ObjectMeta: foosopsSecretMeta.ObjectMeta,
+				Spec: SopsSecretSpec{
+					GPGKeyRefName: fooSopsSecretGPGKeyRefName,
+					StringData: map[string]apiextensionsv1.JSON{
+						"port":     {Raw: []byte(`5432`)},
+						"database": {Raw: []byte(`{"host":"ENC[AES256_GCM,data:abc,type:str]"}`)},
+					},
+				},
+			}
+			err = k8sClient.Create(ctx, quuxSopsSecretObj)
+			Expect(err).NotTo(BeNil())
+			Expect(string(errors.ReasonForError(err))).Should(Equal(lang.ErrSopsSecretSpecStringDataStructured))
+		})
+
+		It("Should fail if a file has the name of a stringData key", func() {
+			By("Creating a SopsSecret with a file named fooStringDataKey")
+			corgeSopsSecretObj := &SopsSecret{
+				TypeMeta:   foosopsSecretMeta.TypeMeta,
+				ObjectMeta: foosopsSecretMeta.ObjectMeta,
+				Spec: SopsSecretSpec{
+					GPGKeyRefName: fooSopsSecretGPGKeyRefName,
+					StringData:    fooSopsSecretStringData,
+					Files: map[string]apiextensionsv1.JSON{
+						"fooStringDataKey": {Raw: []byte(`{"user":"ENC[AES256_GCM,data:abc,type:str]"}`)},
+					},
+				},
+			}
+			err = k8sClient.Create(ctx, corgeSopsSecretObj)
+			Expect(err).NotTo(BeNil())
+			Expect(string(errors.ReasonForError(err))).Should(Equal(lang.ErrSopsSecretSpecFilesInvalid))
+		})
+
+		It("Should fail if sourceRef sets more than one source", func() {
+			By("Creating a SopsSecret whose sourceRef sets a configMap and a secret")
+			graultSopsSecretObj := &SopsSecret{
+				TypeMeta:   foosopsSecretMeta.TypeMeta,
+				ObjectMeta: foosopsSecretMeta.ObjectMeta,
+				Spec: SopsSecretSpec{
+					GPGKeyRefName: fooSopsSecretGPGKeyRefName,
+					SourceRef: &SopsSourceReference{
+						Format:    "yaml",
+						ConfigMap: &SourceKeySelector{Name: "keystore", Key: "secrets.enc.yaml"},
+						Secret:    &SourceKeySelector{Name: "keystore", Key: "secrets.enc.yaml"},
+					},
+				},
+			}
+			err = k8sClient.Create(ctx, graultSopsSecretObj)
+			Expect(err).NotTo(BeNil())
+			Expect(string(errors.ReasonForError(err))).Should(Equal(lang.ErrSopsSecretSpecSourceRefInvalid))
+		})
+
+		It("Should fail if a tls secret has no tls.key", func() {
+			By("Creating a kubernetes.io/tls SopsSecret only setting tls.crt")
+			garplySopsSecretObj := &SopsSecret{
+				TypeMeta:   foosopsSecretMeta.TypeMeta,
+				ObjectMeta: foosopsSecretMeta.ObjectMeta,
+				Spec: SopsSecretSpec{
+					GPGKeyRefName: fooSopsSecretGPGKeyRefName,
+					Type:          "kubernetes.io/tls",
+					StringData: map[string]apiextensionsv1.JSON{
+						"tls.crt": {Raw: []byte(`"ENC[AES256_GCM,data:abc,type:str]"`)},
+					},
+				},
+			}
+			err = k8sClient.Create(ctx, garplySopsSecretObj)
+			Expect(err).NotTo(BeNil())
+			Expect(string(errors.ReasonForError(err))).Should(Equal(lang.ErrSopsSecretSpecTypeKeysMissing))
+		})
+
+		It("Should fail if a typed helper doesn't match the type", func() {
+			By("Creating an Opaque SopsSecret with a dockerConfig")
+			waldoSopsSecretObj := &SopsSecret{
+				TypeMeta:   foosopsSecretMeta.TypeMeta,
+				ObjectMeta: foosopsSecretMeta.ObjectMeta,
+				Spec: SopsSecretSpec{
+					GPGKeyRefName: fooSopsSecretGPGKeyRefName,
+					Type:          "Opaque",
+					DockerConfig: &DockerConfigHelper{
+						Registry: "registry.example.com",
+						Username: "ENC[AES256_GCM,data:abc,type:str]",
+						Password: "ENC[AES256_GCM,data:def,type:str]",
+					},
+				},
+			}
+			err = k8sClient.Create(ctx, waldoSopsSecretObj)
+			Expect(err).NotTo(BeNil())
+			Expect(string(errors.ReasonForError(err))).Should(Equal(lang.ErrSopsSecretSpecHelpersInvalid))
+		})
 	})
 })
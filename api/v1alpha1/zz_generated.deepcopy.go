@@ -22,6 +22,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -55,27 +57,559 @@ func (in *AzureKmsItem) DeepCopy() *AzureKmsItem {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BasicAuthHelper) DeepCopyInto(out *BasicAuthHelper) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BasicAuthHelper.
+func (in *BasicAuthHelper) DeepCopy() *BasicAuthHelper {
+	if in == nil {
+		return nil
+	}
+	out := new(BasicAuthHelper)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DockerConfigHelper) DeepCopyInto(out *DockerConfigHelper) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DockerConfigHelper.
+func (in *DockerConfigHelper) DeepCopy() *DockerConfigHelper {
+	if in == nil {
+		return nil
+	}
+	out := new(DockerConfigHelper)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GPGKey) DeepCopyInto(out *GPGKey) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPGKey.
+func (in *GPGKey) DeepCopy() *GPGKey {
+	if in == nil {
+		return nil
+	}
+	out := new(GPGKey)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GPGKey) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPGKeyAllowedSecrets) DeepCopyInto(out *GPGKeyAllowedSecrets) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPGKeyAllowedSecrets.
+func (in *GPGKeyAllowedSecrets) DeepCopy() *GPGKeyAllowedSecrets {
+	if in == nil {
+		return nil
+	}
+	out := new(GPGKeyAllowedSecrets)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPGKeyGenerateSpec) DeepCopyInto(out *GPGKeyGenerateSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPGKeyGenerateSpec.
+func (in *GPGKeyGenerateSpec) DeepCopy() *GPGKeyGenerateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GPGKeyGenerateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPGKeyGrant) DeepCopyInto(out *GPGKeyGrant) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPGKeyGrant.
+func (in *GPGKeyGrant) DeepCopy() *GPGKeyGrant {
+	if in == nil {
+		return nil
+	}
+	out := new(GPGKeyGrant)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GPGKeyGrant) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPGKeyGrantFrom) DeepCopyInto(out *GPGKeyGrantFrom) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPGKeyGrantFrom.
+func (in *GPGKeyGrantFrom) DeepCopy() *GPGKeyGrantFrom {
+	if in == nil {
+		return nil
+	}
+	out := new(GPGKeyGrantFrom)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPGKeyGrantList) DeepCopyInto(out *GPGKeyGrantList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GPGKeyGrant, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPGKeyGrantList.
+func (in *GPGKeyGrantList) DeepCopy() *GPGKeyGrantList {
+	if in == nil {
+		return nil
+	}
+	out := new(GPGKeyGrantList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GPGKeyGrantList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPGKeyGrantSpec) DeepCopyInto(out *GPGKeyGrantSpec) {
+	*out = *in
+	if in.From != nil {
+		in, out := &in.From, &out.From
+		*out = make([]GPGKeyGrantFrom, len(*in))
+		copy(*out, *in)
+	}
+	if in.To != nil {
+		in, out := &in.To, &out.To
+		*out = make([]GPGKeyGrantTo, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPGKeyGrantSpec.
+func (in *GPGKeyGrantSpec) DeepCopy() *GPGKeyGrantSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GPGKeyGrantSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPGKeyGrantTo) DeepCopyInto(out *GPGKeyGrantTo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPGKeyGrantTo.
+func (in *GPGKeyGrantTo) DeepCopy() *GPGKeyGrantTo {
+	if in == nil {
+		return nil
+	}
+	out := new(GPGKeyGrantTo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPGKeyList) DeepCopyInto(out *GPGKeyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]GPGKey, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPGKeyList.
+func (in *GPGKeyList) DeepCopy() *GPGKeyList {
+	if in == nil {
+		return nil
+	}
+	out := new(GPGKeyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *GPGKeyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPGKeyReference) DeepCopyInto(out *GPGKeyReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPGKeyReference.
+func (in *GPGKeyReference) DeepCopy() *GPGKeyReference {
+	if in == nil {
+		return nil
+	}
+	out := new(GPGKeyReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPGKeySpec) DeepCopyInto(out *GPGKeySpec) {
+	*out = *in
+	if in.Generate != nil {
+		in, out := &in.Generate, &out.Generate
+		*out = new(GPGKeyGenerateSpec)
+		**out = **in
+	}
+	if in.AllowedSecrets != nil {
+		in, out := &in.AllowedSecrets, &out.AllowedSecrets
+		*out = new(GPGKeyAllowedSecrets)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPGKeySpec.
+func (in *GPGKeySpec) DeepCopy() *GPGKeySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GPGKeySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPGKeyStatus) DeepCopyInto(out *GPGKeyStatus) {
+	*out = *in
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPGKeyStatus.
+func (in *GPGKeyStatus) DeepCopy() *GPGKeyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(GPGKeyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GcpKmsDataItem) DeepCopyInto(out *GcpKmsDataItem) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GcpKmsDataItem.
+func (in *GcpKmsDataItem) DeepCopy() *GcpKmsDataItem {
+	if in == nil {
+		return nil
+	}
+	out := new(GcpKmsDataItem)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HcVaultItem) DeepCopyInto(out *HcVaultItem) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HcVaultItem.
+func (in *HcVaultItem) DeepCopy() *HcVaultItem {
+	if in == nil {
+		return nil
+	}
+	out := new(HcVaultItem)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyRotation) DeepCopyInto(out *KeyRotation) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyRotation.
+func (in *KeyRotation) DeepCopy() *KeyRotation {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyRotation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KeyRotation) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyRotationList) DeepCopyInto(out *KeyRotationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]KeyRotation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyRotationList.
+func (in *KeyRotationList) DeepCopy() *KeyRotationList {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyRotationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *KeyRotationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyRotationSpec) DeepCopyInto(out *KeyRotationSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyRotationSpec.
+func (in *KeyRotationSpec) DeepCopy() *KeyRotationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyRotationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KeyRotationStatus) DeepCopyInto(out *KeyRotationStatus) {
+	*out = *in
+	if in.PendingSopsSecrets != nil {
+		in, out := &in.PendingSopsSecrets, &out.PendingSopsSecrets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PendingSopsFiles != nil {
+		in, out := &in.PendingSopsFiles, &out.PendingSopsFiles
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PendingSopsManifests != nil {
+		in, out := &in.PendingSopsManifests, &out.PendingSopsManifests
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KeyRotationStatus.
+func (in *KeyRotationStatus) DeepCopy() *KeyRotationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KeyRotationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KmsDataItem) DeepCopyInto(out *KmsDataItem) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KmsDataItem.
+func (in *KmsDataItem) DeepCopy() *KmsDataItem {
+	if in == nil {
+		return nil
+	}
+	out := new(KmsDataItem)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManifestObjectReference) DeepCopyInto(out *ManifestObjectReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManifestObjectReference.
+func (in *ManifestObjectReference) DeepCopy() *ManifestObjectReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ManifestObjectReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCISource) DeepCopyInto(out *OCISource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCISource.
+func (in *OCISource) DeepCopy() *OCISource {
+	if in == nil {
+		return nil
+	}
+	out := new(OCISource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgpDataItem) DeepCopyInto(out *PgpDataItem) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgpDataItem.
+func (in *PgpDataItem) DeepCopy() *PgpDataItem {
+	if in == nil {
+		return nil
+	}
+	out := new(PgpDataItem)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RolloutOnChange) DeepCopyInto(out *RolloutOnChange) {
+	*out = *in
+	if in.Workloads != nil {
+		in, out := &in.Workloads, &out.Workloads
+		*out = make([]WorkloadReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RolloutOnChange.
+func (in *RolloutOnChange) DeepCopy() *RolloutOnChange {
+	if in == nil {
+		return nil
+	}
+	out := new(RolloutOnChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SopsDocument) DeepCopyInto(out *SopsDocument) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SopsDocument.
+func (in *SopsDocument) DeepCopy() *SopsDocument {
+	if in == nil {
+		return nil
+	}
+	out := new(SopsDocument)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SopsFile) DeepCopyInto(out *SopsFile) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
 	out.Status = in.Status
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPGKey.
-func (in *GPGKey) DeepCopy() *GPGKey {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SopsFile.
+func (in *SopsFile) DeepCopy() *SopsFile {
 	if in == nil {
 		return nil
 	}
-	out := new(GPGKey)
+	out := new(SopsFile)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *GPGKey) DeepCopyObject() runtime.Object {
+func (in *SopsFile) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -83,31 +617,31 @@ func (in *GPGKey) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GPGKeyList) DeepCopyInto(out *GPGKeyList) {
+func (in *SopsFileList) DeepCopyInto(out *SopsFileList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]GPGKey, len(*in))
+		*out = make([]SopsFile, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPGKeyList.
-func (in *GPGKeyList) DeepCopy() *GPGKeyList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SopsFileList.
+func (in *SopsFileList) DeepCopy() *SopsFileList {
 	if in == nil {
 		return nil
 	}
-	out := new(GPGKeyList)
+	out := new(SopsFileList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *GPGKeyList) DeepCopyObject() runtime.Object {
+func (in *SopsFileList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -115,91 +649,196 @@ func (in *GPGKeyList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GPGKeySpec) DeepCopyInto(out *GPGKeySpec) {
+func (in *SopsFileSpec) DeepCopyInto(out *SopsFileSpec) {
 	*out = *in
+	if in.GPGKeyRefs != nil {
+		in, out := &in.GPGKeyRefs, &out.GPGKeyRefs
+		*out = make([]GPGKeyReference, len(*in))
+		copy(*out, *in)
+	}
+	out.Target = in.Target
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPGKeySpec.
-func (in *GPGKeySpec) DeepCopy() *GPGKeySpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SopsFileSpec.
+func (in *SopsFileSpec) DeepCopy() *SopsFileSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(GPGKeySpec)
+	out := new(SopsFileSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GPGKeyStatus) DeepCopyInto(out *GPGKeyStatus) {
+func (in *SopsFileStatus) DeepCopyInto(out *SopsFileStatus) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPGKeyStatus.
-func (in *GPGKeyStatus) DeepCopy() *GPGKeyStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SopsFileStatus.
+func (in *SopsFileStatus) DeepCopy() *SopsFileStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(GPGKeyStatus)
+	out := new(SopsFileStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GcpKmsDataItem) DeepCopyInto(out *GcpKmsDataItem) {
+func (in *SopsFileTarget) DeepCopyInto(out *SopsFileTarget) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GcpKmsDataItem.
-func (in *GcpKmsDataItem) DeepCopy() *GcpKmsDataItem {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SopsFileTarget.
+func (in *SopsFileTarget) DeepCopy() *SopsFileTarget {
 	if in == nil {
 		return nil
 	}
-	out := new(GcpKmsDataItem)
+	out := new(SopsFileTarget)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *HcVaultItem) DeepCopyInto(out *HcVaultItem) {
+func (in *SopsKeyGroup) DeepCopyInto(out *SopsKeyGroup) {
+	*out = *in
+	if in.AwsKms != nil {
+		in, out := &in.AwsKms, &out.AwsKms
+		*out = make([]KmsDataItem, len(*in))
+		copy(*out, *in)
+	}
+	if in.Pgp != nil {
+		in, out := &in.Pgp, &out.Pgp
+		*out = make([]PgpDataItem, len(*in))
+		copy(*out, *in)
+	}
+	if in.AzureKms != nil {
+		in, out := &in.AzureKms, &out.AzureKms
+		*out = make([]AzureKmsItem, len(*in))
+		copy(*out, *in)
+	}
+	if in.HcVault != nil {
+		in, out := &in.HcVault, &out.HcVault
+		*out = make([]HcVaultItem, len(*in))
+		copy(*out, *in)
+	}
+	if in.GcpKms != nil {
+		in, out := &in.GcpKms, &out.GcpKms
+		*out = make([]GcpKmsDataItem, len(*in))
+		copy(*out, *in)
+	}
+	if in.Age != nil {
+		in, out := &in.Age, &out.Age
+		*out = make([]AgeItem, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SopsKeyGroup.
+func (in *SopsKeyGroup) DeepCopy() *SopsKeyGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(SopsKeyGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SopsManifest) DeepCopyInto(out *SopsManifest) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HcVaultItem.
-func (in *HcVaultItem) DeepCopy() *HcVaultItem {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SopsManifest.
+func (in *SopsManifest) DeepCopy() *SopsManifest {
 	if in == nil {
 		return nil
 	}
-	out := new(HcVaultItem)
+	out := new(SopsManifest)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SopsManifest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KmsDataItem) DeepCopyInto(out *KmsDataItem) {
+func (in *SopsManifestList) DeepCopyInto(out *SopsManifestList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SopsManifest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SopsManifestList.
+func (in *SopsManifestList) DeepCopy() *SopsManifestList {
+	if in == nil {
+		return nil
+	}
+	out := new(SopsManifestList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SopsManifestList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SopsManifestSpec) DeepCopyInto(out *SopsManifestSpec) {
 	*out = *in
+	if in.GPGKeyRefs != nil {
+		in, out := &in.GPGKeyRefs, &out.GPGKeyRefs
+		*out = make([]GPGKeyReference, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KmsDataItem.
-func (in *KmsDataItem) DeepCopy() *KmsDataItem {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SopsManifestSpec.
+func (in *SopsManifestSpec) DeepCopy() *SopsManifestSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(KmsDataItem)
+	out := new(SopsManifestSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PgpDataItem) DeepCopyInto(out *PgpDataItem) {
+func (in *SopsManifestStatus) DeepCopyInto(out *SopsManifestStatus) {
 	*out = *in
+	if in.Object != nil {
+		in, out := &in.Object, &out.Object
+		*out = new(ManifestObjectReference)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgpDataItem.
-func (in *PgpDataItem) DeepCopy() *PgpDataItem {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SopsManifestStatus.
+func (in *SopsManifestStatus) DeepCopy() *SopsManifestStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PgpDataItem)
+	out := new(SopsManifestStatus)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -237,6 +876,18 @@ func (in *SopsMetadata) DeepCopyInto(out *SopsMetadata) {
 		*out = make([]AgeItem, len(*in))
 		copy(*out, *in)
 	}
+	if in.KeyGroups != nil {
+		in, out := &in.KeyGroups, &out.KeyGroups
+		*out = make([]SopsKeyGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Raw != nil {
+		in, out := &in.Raw, &out.Raw
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SopsMetadata.
@@ -255,7 +906,7 @@ func (in *SopsSecret) DeepCopyInto(out *SopsSecret) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 	in.Sops.DeepCopyInto(&out.Sops)
 }
 
@@ -314,11 +965,58 @@ func (in *SopsSecretSpec) DeepCopyInto(out *SopsSecretSpec) {
 	*out = *in
 	if in.StringData != nil {
 		in, out := &in.StringData, &out.StringData
-		*out = make(map[string]string, len(*in))
+		*out = make(map[string]apiextensionsv1.JSON, len(*in))
 		for key, val := range *in {
-			(*out)[key] = val
+			(*out)[key] = *val.DeepCopy()
 		}
 	}
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make(map[string]apiextensionsv1.JSON, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Document != nil {
+		in, out := &in.Document, &out.Document
+		*out = new(SopsDocument)
+		**out = **in
+	}
+	if in.SourceRef != nil {
+		in, out := &in.SourceRef, &out.SourceRef
+		*out = new(SopsSourceReference)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DockerConfig != nil {
+		in, out := &in.DockerConfig, &out.DockerConfig
+		*out = new(DockerConfigHelper)
+		**out = **in
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(TLSHelper)
+		**out = **in
+	}
+	if in.BasicAuth != nil {
+		in, out := &in.BasicAuth, &out.BasicAuth
+		*out = new(BasicAuthHelper)
+		**out = **in
+	}
+	if in.GPGKeyRefs != nil {
+		in, out := &in.GPGKeyRefs, &out.GPGKeyRefs
+		*out = make([]GPGKeyReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.RolloutOnChange != nil {
+		in, out := &in.RolloutOnChange, &out.RolloutOnChange
+		*out = new(RolloutOnChange)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RefreshInterval != nil {
+		in, out := &in.RefreshInterval, &out.RefreshInterval
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SopsSecretSpec.
@@ -334,6 +1032,10 @@ func (in *SopsSecretSpec) DeepCopy() *SopsSecretSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SopsSecretStatus) DeepCopyInto(out *SopsSecretStatus) {
 	*out = *in
+	if in.LastSyncedAt != nil {
+		in, out := &in.LastSyncedAt, &out.LastSyncedAt
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SopsSecretStatus.
@@ -345,3 +1047,78 @@ func (in *SopsSecretStatus) DeepCopy() *SopsSecretStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SopsSourceReference) DeepCopyInto(out *SopsSourceReference) {
+	*out = *in
+	if in.ConfigMap != nil {
+		in, out := &in.ConfigMap, &out.ConfigMap
+		*out = new(SourceKeySelector)
+		**out = **in
+	}
+	if in.Secret != nil {
+		in, out := &in.Secret, &out.Secret
+		*out = new(SourceKeySelector)
+		**out = **in
+	}
+	if in.OCI != nil {
+		in, out := &in.OCI, &out.OCI
+		*out = new(OCISource)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SopsSourceReference.
+func (in *SopsSourceReference) DeepCopy() *SopsSourceReference {
+	if in == nil {
+		return nil
+	}
+	out := new(SopsSourceReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SourceKeySelector) DeepCopyInto(out *SourceKeySelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SourceKeySelector.
+func (in *SourceKeySelector) DeepCopy() *SourceKeySelector {
+	if in == nil {
+		return nil
+	}
+	out := new(SourceKeySelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TLSHelper) DeepCopyInto(out *TLSHelper) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSHelper.
+func (in *TLSHelper) DeepCopy() *TLSHelper {
+	if in == nil {
+		return nil
+	}
+	out := new(TLSHelper)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkloadReference) DeepCopyInto(out *WorkloadReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkloadReference.
+func (in *WorkloadReference) DeepCopy() *WorkloadReference {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkloadReference)
+	in.DeepCopyInto(out)
+	return out
+}
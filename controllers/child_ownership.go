@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+)
+
+// backupManifestKey holds the manifest of an adopted object other than a
+// secret in its backup secret
+const backupManifestKey = "manifest.yaml"
+
+// mayManage checks whether owner may write an existing object: it controls
+// it already, the object lost its owner reference to owner, or
+// adoptionPolicy lets owner take it over
+func mayManage(owner metav1.Object, adoptionPolicy string, object metav1.Object) bool {
+	return metav1.IsControlledBy(object, owner) || lostOwnerReference(owner, object) || mayAdopt(adoptionPolicy, object)
+}
+
+// lostOwnerReference checks for an object written by owner which has no
+// controller anymore, e.g. after its owner reference was removed by hand.
+// Objects orphaned on purpose lose the owner uid annotation as well.
+func lostOwnerReference(owner metav1.Object, object metav1.Object) bool {
+	if metav1.GetControllerOf(object) != nil || owner.GetUID() == "" {
+		return false
+	}
+	return object.GetAnnotations()[gitopssecretsnappcloudiov1alpha1.SopsSecretOwnerUIDAnnotation] == string(owner.GetUID())
+}
+
+// mayAdopt checks an adoption policy against an existing object which isn't
+// controlled yet by the one adopting it
+func mayAdopt(adoptionPolicy string, object metav1.Object) bool {
+	switch adoptionPolicy {
+	case gitopssecretsnappcloudiov1alpha1.AdoptionPolicyNever:
+		return false
+	case gitopssecretsnappcloudiov1alpha1.AdoptionPolicyAlways:
+		return metav1.GetControllerOf(object) == nil || isAnnotatedToBeManaged(object)
+	default:
+		return isAnnotatedToBeManaged(object)
+	}
+}
+
+// checks if the annotation equals to "true", and it's case sensitive
+func isAnnotatedToBeManaged(object metav1.Object) bool {
+	return object.GetAnnotations()[gitopssecretsnappcloudiov1alpha1.SopsSecretManagedAnnotation] == "true"
+}
+
+// backupAdoptedObject copies an existing object to a secret named
+// <name>-backup-<uid prefix> before it gets adopted, with the time of the
+// backup in an annotation. A secret keeps its data, any other object its
+// whole manifest, as it may be just as sensitive. The backup is Opaque, so
+// any secret type can be kept, and has no owner so it outlives its adopter.
+// It is named after the uid of the object, so retries of a failed adoption
+// don't pile up backups.
+func backupAdoptedObject(ctx context.Context, c client.Client, logger logr.Logger, object client.Object) error {
+	backup := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backupName(object),
+			Namespace: object.GetNamespace(),
+		},
+		Type: corev1.SecretTypeOpaque,
+	}
+	if secret, ok := object.(*corev1.Secret); ok {
+		backup.Annotations = map[string]string{
+			gitopssecretsnappcloudiov1alpha1.SopsSecretBackupOfAnnotation: fmt.Sprintf("%s (%s)", secret.Name, secret.Type),
+		}
+		backup.Data = secret.Data
+	} else {
+		manifest := object.DeepCopyObject().(client.Object)
+		manifest.SetManagedFields(nil)
+		manifestYAML, err := yaml.Marshal(manifest)
+		if err != nil {
+			return err
+		}
+		backup.Annotations = map[string]string{
+			gitopssecretsnappcloudiov1alpha1.SopsSecretBackupOfAnnotation: fmt.Sprintf("%s (%s)", object.GetName(), object.GetObjectKind().GroupVersionKind().Kind),
+		}
+		backup.Data = map[string][]byte{backupManifestKey: manifestYAML}
+	}
+	backup.Annotations[gitopssecretsnappcloudiov1alpha1.SopsSecretBackedUpAtAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	logger.Info(
+		"Backing up object before adopting it",
+		"object", object.GetName(),
+		"backup", backup.Name,
+		"namespace", object.GetNamespace(),
+	)
+	if err := c.Create(ctx, backup); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// backupName returns <name>-backup-<uid prefix>, cutting the name short to
+// keep within the length of a dns subdomain
+func backupName(object metav1.Object) string {
+	suffix := "-backup-" + strings.SplitN(string(object.GetUID()), "-", 2)[0]
+	name := object.GetName()
+	if len(name)+len(suffix) > validation.DNS1123SubdomainMaxLength {
+		name = strings.TrimRight(name[:validation.DNS1123SubdomainMaxLength-len(suffix)], "-.")
+	}
+	return name + suffix
+}
+
+// orphanObject strips the owner reference of owner, the managed annotation and
+// the owner uid annotation from object, so it survives the deletion of owner
+// and is only taken over again as its adoptionPolicy allows
+func orphanObject(ctx context.Context, c client.Client, logger logr.Logger, owner metav1.Object, object client.Object) error {
+	var ownerReferences []metav1.OwnerReference
+	for _, ownerReference := range object.GetOwnerReferences() {
+		if ownerReference.UID != owner.GetUID() {
+			ownerReferences = append(ownerReferences, ownerReference)
+		}
+	}
+	annotations := object.GetAnnotations()
+	_, annotated := annotations[gitopssecretsnappcloudiov1alpha1.SopsSecretManagedAnnotation]
+	_, ownedByUID := annotations[gitopssecretsnappcloudiov1alpha1.SopsSecretOwnerUIDAnnotation]
+	if len(ownerReferences) == len(object.GetOwnerReferences()) && !annotated && !ownedByUID {
+		return nil
+	}
+	object.SetOwnerReferences(ownerReferences)
+	delete(annotations, gitopssecretsnappcloudiov1alpha1.SopsSecretManagedAnnotation)
+	delete(annotations, gitopssecretsnappcloudiov1alpha1.SopsSecretOwnerUIDAnnotation)
+	object.SetAnnotations(annotations)
+
+	logger.Info(
+		"Orphaning object",
+		"object", object.GetName(),
+		"namespace", object.GetNamespace(),
+	)
+	return c.Update(ctx, object)
+}
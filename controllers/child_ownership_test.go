@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+)
+
+var _ = Describe("Child ownership", func() {
+	owner := &gitopssecretsnappcloudiov1alpha1.SopsSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "team", UID: "c0ffee00-0000-0000-0000-000000000000"},
+	}
+	writtenByOperator := func(ownerUID string) *corev1.Secret {
+		return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:        "example",
+			Namespace:   "team",
+			Annotations: map[string]string{gitopssecretsnappcloudiov1alpha1.SopsSecretOwnerUIDAnnotation: ownerUID},
+			ManagedFields: []metav1.ManagedFieldsEntry{{
+				Manager:   sopsSecretFieldManager,
+				Operation: metav1.ManagedFieldsOperationApply,
+			}},
+		}}
+	}
+
+	It("Should take back its own secret whose owner reference was removed", func() {
+		Expect(mayManage(owner, gitopssecretsnappcloudiov1alpha1.AdoptionPolicyNever, writtenByOperator(string(owner.UID)))).To(BeTrue())
+	})
+
+	It("Should honour the adoption policy for secrets of another owner", func() {
+		orphaned := writtenByOperator("deleted-owner")
+		Expect(mayManage(owner, gitopssecretsnappcloudiov1alpha1.AdoptionPolicyNever, orphaned)).To(BeFalse())
+		Expect(mayManage(owner, gitopssecretsnappcloudiov1alpha1.AdoptionPolicyIfAnnotated, orphaned)).To(BeFalse())
+		Expect(mayManage(owner, gitopssecretsnappcloudiov1alpha1.AdoptionPolicyAlways, orphaned)).To(BeTrue())
+	})
+
+	It("Should keep backup names within the dns subdomain limit", func() {
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name: strings.Repeat("a", 240) + ".b" + strings.Repeat("c", 11),
+			UID:  "0123abcd-0000-0000-0000-000000000000",
+		}}
+		name := backupName(secret)
+		Expect(validation.IsDNS1123Subdomain(name)).To(BeEmpty())
+		Expect(name).To(HaveSuffix("-backup-0123abcd"))
+
+		secret.Name = "example"
+		Expect(backupName(secret)).To(Equal("example-backup-0123abcd"))
+	})
+})
@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/mail"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+)
+
+// gpgKeyInfo holds the details of a gpg key that are read from its colon listing
+type gpgKeyInfo struct {
+	Fingerprint string
+	// ExpiresAt is zero when the key never expires
+	ExpiresAt time.Time
+}
+
+// readGPGKeyInfo lists the key stored in keyFilePath without importing it
+func readGPGKeyInfo(keyFilePath string) (*gpgKeyInfo, error) {
+	listing, err := runGPG("", nil, "--batch", "--with-colons", "--import-options", "show-only", "--import", keyFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return parseGPGKeyColons(string(listing))
+}
+
+// parseGPGKeyColons parses the output of gpg --with-colons for a single key.
+// The key is usable until either the primary key expires or the last
+// encryption capable subkey expires, whichever comes first.
+func parseGPGKeyColons(output string) (*gpgKeyInfo, error) {
+	info := &gpgKeyInfo{}
+	var primaryExpiry, subkeyExpiry time.Time
+	foundPrimary, primaryFingerprintNext, encryptionSubkeyNeverExpires := false, false, false
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, ":")
+		switch fields[0] {
+		case "sec", "pub":
+			if foundPrimary {
+				return nil, fmt.Errorf("expected a single gpg key, found more")
+			}
+			if len(fields) < 12 {
+				return nil, fmt.Errorf("malformed gpg key record %q", line)
+			}
+			foundPrimary, primaryFingerprintNext = true, true
+			expiry, err := parseGPGTimestamp(fields[6])
+			if err != nil {
+				return nil, err
+			}
+			primaryExpiry = expiry
+		case "fpr":
+			if primaryFingerprintNext && len(fields) > 9 {
+				info.Fingerprint = fields[9]
+			}
+			primaryFingerprintNext = false
+		case "ssb", "sub":
+			if len(fields) < 12 {
+				return nil, fmt.Errorf("malformed gpg subkey record %q", line)
+			}
+			// skip revoked subkeys and the ones which can't encrypt
+			if fields[1] == "r" || !strings.Contains(fields[11], "e") {
+				continue
+			}
+			expiry, err := parseGPGTimestamp(fields[6])
+			if err != nil {
+				return nil, err
+			}
+			if expiry.IsZero() {
+				encryptionSubkeyNeverExpires = true
+			} else if expiry.After(subkeyExpiry) {
+				subkeyExpiry = expiry
+			}
+		}
+	}
+	if !foundPrimary {
+		return nil, fmt.Errorf("no gpg key found")
+	}
+	if encryptionSubkeyNeverExpires {
+		subkeyExpiry = time.Time{}
+	}
+
+	info.ExpiresAt = primaryExpiry
+	if !subkeyExpiry.IsZero() && (info.ExpiresAt.IsZero() || subkeyExpiry.Before(info.ExpiresAt)) {
+		info.ExpiresAt = subkeyExpiry
+	}
+	return info, nil
+}
+
+// parseGPGTimestamp parses the seconds since epoch format used by gpg, empty means never
+func parseGPGTimestamp(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid gpg timestamp %q: %v", value, err)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// generatedGPGKey is a keypair created by generateGPGKey
+type generatedGPGKey struct {
+	Fingerprint string
+	// ArmoredPrivateKey is stripped of its armor header and footer, as in GPGKey.Spec
+	ArmoredPrivateKey string
+	ArmoredPublicKey  string
+}
+
+// generateGPGKey creates a keypair in a throwaway gpg home directory, so that
+// the operator keyring only ever gets keys through the usual import
+func generateGPGKey(spec *gitopssecretsnappcloudiov1alpha1.GPGKeyGenerateSpec, passphrase string) (*generatedGPGKey, error) {
+	homeDir, err := os.MkdirTemp("", "gpgkey-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.RemoveAll(homeDir)
+	}()
+
+	_, err = runGPG(homeDir, strings.NewReader(gpgKeyGenerationParameters(spec, passphrase)),
+		"--batch", "--pinentry-mode=loopback", "--gen-key")
+	if err != nil {
+		return nil, err
+	}
+
+	listing, err := runGPG(homeDir, nil, "--batch", "--with-colons", "--list-secret-keys")
+	if err != nil {
+		return nil, err
+	}
+	keyInfo, err := parseGPGKeyColons(string(listing))
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := runGPG(homeDir, nil, "--batch", "--pinentry-mode=loopback", "--passphrase", passphrase,
+		"--armor", "--export-secret-keys", keyInfo.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := runGPG(homeDir, nil, "--batch", "--armor", "--export", keyInfo.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
+
+	return &generatedGPGKey{
+		Fingerprint:       keyInfo.Fingerprint,
+		ArmoredPrivateKey: stripArmor(string(privateKey)),
+		ArmoredPublicKey:  string(publicKey),
+	}, nil
+}
+
+// exportGPGPublicKey exports the armored public key from the operator keyring.
+// gpg exports every key of the keyring without a fingerprint, so it is required.
+func exportGPGPublicKey(fingerprint string) (string, error) {
+	if fingerprint == "" {
+		return "", fmt.Errorf("no fingerprint to export the public key of")
+	}
+	publicKey, err := runGPG("", nil, "--batch", "--armor", "--export", fingerprint)
+	if err != nil {
+		return "", err
+	}
+	if len(publicKey) == 0 {
+		return "", fmt.Errorf("no public key found for %s", fingerprint)
+	}
+	return string(publicKey), nil
+}
+
+// hasGPGSecretKey checks whether the secret key of fingerprint is in the
+// operator keyring
+func hasGPGSecretKey(fingerprint string) bool {
+	_, err := runGPG("", nil, "--batch", "--list-secret-keys", fingerprint)
+	return err == nil
+}
+
+func gpgKeyGenerationParameters(spec *gitopssecretsnappcloudiov1alpha1.GPGKeyGenerateSpec, passphrase string) string {
+	var params []string
+	switch spec.Algorithm {
+	case "ed25519":
+		params = append(params,
+			"Key-Type: EDDSA",
+			"Key-Curve: ed25519",
+			"Subkey-Type: ECDH",
+			"Subkey-Curve: cv25519",
+		)
+	default:
+		keySize := spec.KeySize
+		if keySize == 0 {
+			keySize = 4096
+		}
+		params = append(params,
+			"Key-Type: RSA",
+			fmt.Sprintf("Key-Length: %d", keySize),
+			"Subkey-Type: RSA",
+			fmt.Sprintf("Subkey-Length: %d", keySize),
+		)
+	}
+
+	uid := strings.TrimSpace(spec.UID)
+	if address, err := mail.ParseAddress(uid); err == nil {
+		if address.Name != "" {
+			params = append(params, "Name-Real: "+address.Name)
+		}
+		params = append(params, "Name-Email: "+address.Address)
+	} else {
+		params = append(params, "Name-Real: "+uid)
+	}
+
+	expiry := spec.Expiry
+	if expiry == "" {
+		expiry = "0"
+	}
+	params = append(params,
+		"Expire-Date: "+expiry,
+		"Passphrase: "+passphrase,
+		"%commit",
+	)
+	return strings.Join(params, "\n") + "\n"
+}
+
+// newGPGKeyPassphrase returns a random passphrase accepted by the GPGKey webhook
+func newGPGKeyPassphrase() (string, error) {
+	randomBytes := make([]byte, 32)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(randomBytes), nil
+}
+
+// stripArmor removes the armor header and footer lines of an armored key
+func stripArmor(armored string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(armored), "\n") {
+		if strings.HasPrefix(line, "-----") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// runGPG runs the gpg binary, on the operator keyring when homeDir is empty
+func runGPG(homeDir string, stdin *strings.Reader, args ...string) ([]byte, error) {
+	if homeDir != "" {
+		args = append([]string{"--homedir", homeDir}, args...)
+	}
+	cmd := exec.Command(gpgBinary(), args...)
+	var stdout, stderr bytes.Buffer
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("gpg failed: %v: %s", err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
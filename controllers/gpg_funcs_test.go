@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("GPG key colon listing", func() {
+	const (
+		neverExpiringKey = `sec:-:4096:1:067EBF5DA6F0220A:1658826409:::-:::escaESCA:::#:::23::0:
+fpr:::::::::32B974509BC4B9DD570AB0E8067EBF5DA6F0220A:
+grp:::::::::0D705B5C5F040BECE03891E082AEA642B4BF2AB2:
+uid:-::::1658826409::C87CA1A1C6EDAF215B26240A6D55063EA25B5560::testgpgkey <test@test.com>::::::::::0:
+ssb:-:4096:1:421D1DBA0B9F966E:1658826409::::::esa:::#:::23:
+fpr:::::::::FEA7B76B81AF174D06AB8886421D1DBA0B9F966E:
+`
+		expiringSubkey = `sec:-:4096:1:067EBF5DA6F0220A:1658826409:1753434409::-:::scSC:::#:::23::0:
+fpr:::::::::32B974509BC4B9DD570AB0E8067EBF5DA6F0220A:
+ssb:-:4096:1:421D1DBA0B9F966E:1658826409:1690362409:::::e:::#:::23:
+fpr:::::::::FEA7B76B81AF174D06AB8886421D1DBA0B9F966E:
+ssb:r:4096:1:521D1DBA0B9F966E:1658826409:1790362409:::::e:::#:::23:
+fpr:::::::::FEA7B76B81AF174D06AB8886521D1DBA0B9F966E:
+`
+	)
+
+	It("Should read the primary fingerprint of a key that never expires", func() {
+		info, err := parseGPGKeyColons(neverExpiringKey)
+		Expect(err).To(BeNil())
+		Expect(info.Fingerprint).To(Equal("32B974509BC4B9DD570AB0E8067EBF5DA6F0220A"))
+		Expect(info.ExpiresAt.IsZero()).To(BeTrue())
+	})
+
+	It("Should use the earliest expiry of the primary key and its encryption subkeys", func() {
+		info, err := parseGPGKeyColons(expiringSubkey)
+		Expect(err).To(BeNil())
+		Expect(info.ExpiresAt).To(Equal(time.Unix(1690362409, 0).UTC()))
+	})
+
+	It("Should fail when no key is listed", func() {
+		_, err := parseGPGKeyColons("")
+		Expect(err).NotTo(BeNil())
+	})
+})
+
+var _ = Describe("GPG public key export", func() {
+	It("Should refuse to export without a fingerprint", func() {
+		// gpg --export "" exports every key of the shared keyring
+		_, err := exportGPGPublicKey("")
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"go.mozilla.org/sops/v3"
+	"go.mozilla.org/sops/v3/pgp"
+	sopsdotenv "go.mozilla.org/sops/v3/stores/dotenv"
+	sopsini "go.mozilla.org/sops/v3/stores/ini"
+	sopsjson "go.mozilla.org/sops/v3/stores/json"
+	sopsyaml "go.mozilla.org/sops/v3/stores/yaml"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+)
+
+// gpgKeyReferences returns the GPGKey references of the kinds which are
+// decrypted with GPGKeys
+func gpgKeyReferences(obj client.Object) []gitopssecretsnappcloudiov1alpha1.GPGKeyReference {
+	switch typed := obj.(type) {
+	case *gitopssecretsnappcloudiov1alpha1.SopsSecret:
+		return typed.Spec.GetGPGKeyRefs()
+	case *gitopssecretsnappcloudiov1alpha1.SopsFile:
+		return typed.Spec.GPGKeyRefs
+	case *gitopssecretsnappcloudiov1alpha1.SopsManifest:
+		return typed.Spec.GPGKeyRefs
+	}
+	return nil
+}
+
+// gpgKeyReferenceTo returns the reference of obj pointing to gpgKey, a
+// reference without namespace points to the namespace of obj
+func gpgKeyReferenceTo(obj client.Object, gpgKey types.NamespacedName) (gitopssecretsnappcloudiov1alpha1.GPGKeyReference, bool) {
+	for _, ref := range gpgKeyReferences(obj) {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = obj.GetNamespace()
+		}
+		if ref.Name == gpgKey.Name && namespace == gpgKey.Namespace {
+			return ref, true
+		}
+	}
+	return gitopssecretsnappcloudiov1alpha1.GPGKeyReference{}, false
+}
+
+// listGPGKeyReferrers lists the objects of list, in every namespace, which
+// reference gpgKey
+func listGPGKeyReferrers(ctx context.Context, c client.Client, list client.ObjectList, gpgKey types.NamespacedName) ([]client.Object, error) {
+	if err := c.List(ctx, list); err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	var referrers []client.Object
+	for _, item := range items {
+		obj, ok := item.(client.Object)
+		if !ok {
+			continue
+		}
+		if _, ok := gpgKeyReferenceTo(obj, gpgKey); ok {
+			referrers = append(referrers, obj)
+		}
+	}
+	return referrers, nil
+}
+
+// requestsReferencingGPGKey enqueues the objects of list referencing the
+// GPGKey obj, so the ones waiting after a crypto error are retried once the
+// key changes
+func requestsReferencingGPGKey(ctx context.Context, c client.Client, logger logr.Logger, list client.ObjectList, obj client.Object) []reconcile.Request {
+	referrers, err := listGPGKeyReferrers(ctx, c, list, client.ObjectKeyFromObject(obj))
+	if err != nil {
+		logger.Info("Couldn't list the objects referencing the GPGKey", "GPGKey", client.ObjectKeyFromObject(obj), "error", err)
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(referrers))
+	for _, referrer := range referrers {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(referrer)})
+	}
+	return requests
+}
+
+// gpgKeyReferrersHandler enqueues the objects of the lists built by newList
+// which reference a changed GPGKey
+func gpgKeyReferrersHandler(c client.Client, logger logr.Logger, newList func() client.ObjectList) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+		return requestsReferencingGPGKey(ctx, c, logger, newList(), obj)
+	})
+}
+
+// sopsStoreForFormat returns the sops store of a document format, binary
+// documents are stored by sops as json
+func sopsStoreForFormat(format string) sops.Store {
+	switch format {
+	case "json":
+		return &sopsjson.Store{}
+	case "yaml":
+		return &sopsyaml.Store{}
+	case "dotenv":
+		return &sopsdotenv.Store{}
+	case "ini":
+		return &sopsini.Store{}
+	default:
+		return &sopsjson.BinaryStore{}
+	}
+}
+
+// sopsDocumentFingerprints returns the pgp fingerprints a sops document is
+// encrypted for
+func sopsDocumentFingerprints(format string, data []byte) ([]string, error) {
+	tree, err := sopsStoreForFormat(format).LoadEncryptedFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading the sops metadata: %w", err)
+	}
+	var fingerprints []string
+	for _, group := range tree.Metadata.KeyGroups {
+		for _, key := range group {
+			if pgpKey, ok := key.(*pgp.MasterKey); ok {
+				fingerprints = append(fingerprints, pgpKey.Fingerprint)
+			}
+		}
+	}
+	return fingerprints, nil
+}
+
+// sopsDocumentKeyGroups returns the number of key groups of a sops document,
+// more than one means its data key is shamir split. An unreadable document
+// has none.
+func sopsDocumentKeyGroups(format string, data []byte) int {
+	tree, err := sopsStoreForFormat(format).LoadEncryptedFile(data)
+	if err != nil {
+		return 0
+	}
+	return len(tree.Metadata.KeyGroups)
+}
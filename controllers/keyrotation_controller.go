@@ -0,0 +1,356 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+)
+
+// KeyRotationReconciler reconciles a KeyRotation object
+type KeyRotationReconciler struct {
+	client.Client
+	Scheme       *runtime.Scheme
+	Log          logr.Logger
+	RequeueAfter int64
+}
+
+//+kubebuilder:rbac:groups=gitopssecret.snappcloud.io,resources=keyrotations,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gitopssecret.snappcloud.io,resources=keyrotations/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=gitopssecret.snappcloud.io,resources=keyrotations/finalizers,verbs=update
+
+// Reconcile reports the SopsSecrets, SopsFiles and SopsManifests of every
+// namespace which still depend on the old GPGKey of a rotation, and keeps that
+// key from being deleted until none are left.
+func (r *KeyRotationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.Log.Info("Reconciling", "keyrotation", req.NamespacedName)
+
+	keyRotation := &gitopssecretsnappcloudiov1alpha1.KeyRotation{}
+	if err := r.Get(ctx, req.NamespacedName, keyRotation); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !keyRotation.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalizeKeyRotation(ctx, keyRotation)
+	}
+	if controllerutil.AddFinalizer(keyRotation, gitopssecretsnappcloudiov1alpha1.KeyRotationFinalizer) {
+		if err := r.Update(ctx, keyRotation); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	oldKey, newKey, rescheduleReconcileLoop := r.getRotatedKeys(ctx, req, keyRotation)
+	if rescheduleReconcileLoop {
+		return reconcile.Result{Requeue: true, RequeueAfter: time.Duration(r.RequeueAfter) * time.Minute}, nil
+	}
+
+	pending, err := r.listPendingObjects(ctx, keyRotation, oldKey, newKey)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if pending.count() > 0 {
+		// keep the old key usable while secrets still depend on it, a key
+		// already being deleted can't get a new finalizer
+		if oldKey.DeletionTimestamp.IsZero() && controllerutil.AddFinalizer(oldKey, gitopssecretsnappcloudiov1alpha1.KeyRotationFinalizer) {
+			if err := r.Update(ctx, oldKey); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		keyRotation.Status.Phase = gitopssecretsnappcloudiov1alpha1.KeyRotationPhaseInProgress
+		keyRotation.Status.Message = fmt.Sprintf("%d SopsSecrets, SopsFiles and SopsManifests are not encrypted for the new key yet", pending.count())
+	} else {
+		if err := r.releaseOldKey(ctx, keyRotation); err != nil {
+			return ctrl.Result{}, err
+		}
+		keyRotation.Status.Phase = gitopssecretsnappcloudiov1alpha1.KeyRotationPhaseCompleted
+		keyRotation.Status.Message = "All SopsSecrets, SopsFiles and SopsManifests are encrypted for the new key, the old key can be deleted"
+	}
+	keyRotation.Status.OldFingerprint = oldKey.Status.Fingerprint
+	keyRotation.Status.NewFingerprint = newKey.Status.Fingerprint
+	keyRotation.Status.PendingSopsSecrets = pending.sopsSecrets
+	keyRotation.Status.PendingSopsFiles = pending.sopsFiles
+	keyRotation.Status.PendingSopsManifests = pending.sopsManifests
+	_ = r.Status().Update(ctx, keyRotation)
+
+	return ctrl.Result{}, nil
+}
+
+func (r *KeyRotationReconciler) getRotatedKeys(
+	ctx context.Context,
+	req ctrl.Request,
+	keyRotation *gitopssecretsnappcloudiov1alpha1.KeyRotation,
+) (*gitopssecretsnappcloudiov1alpha1.GPGKey, *gitopssecretsnappcloudiov1alpha1.GPGKey, bool) {
+	var gpgKeys []*gitopssecretsnappcloudiov1alpha1.GPGKey
+	for _, name := range []string{keyRotation.Spec.OldKeyRef, keyRotation.Spec.NewKeyRef} {
+		gpgKey := &gitopssecretsnappcloudiov1alpha1.GPGKey{}
+		err := r.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: name}, gpgKey)
+		if err == nil && gpgKey.Status.Fingerprint == "" {
+			err = fmt.Errorf("fingerprint of GPGKey %s is not known yet", name)
+		}
+		if err != nil {
+			r.Log.Info("Error fetching GPGKey", "keyrotation", req.NamespacedName, "GPGKey", name, "error", err)
+			keyRotation.Status.Phase = gitopssecretsnappcloudiov1alpha1.KeyRotationPhasePending
+			keyRotation.Status.Message = fmt.Sprintf("GPGKey %s is not ready", name)
+			_ = r.Status().Update(ctx, keyRotation)
+			return nil, nil, true
+		}
+		gpgKeys = append(gpgKeys, gpgKey)
+	}
+	return gpgKeys[0], gpgKeys[1], false
+}
+
+// pendingObjects are the names of the objects which still depend on the old
+// GPGKey of a rotation, by kind
+type pendingObjects struct {
+	sopsSecrets   []string
+	sopsFiles     []string
+	sopsManifests []string
+}
+
+func (p *pendingObjects) count() int {
+	return len(p.sopsSecrets) + len(p.sopsFiles) + len(p.sopsManifests)
+}
+
+// listPendingObjects returns the SopsSecrets, SopsFiles and SopsManifests of
+// every namespace which reference the old GPGKey, through a granted reference
+// from other namespaces, and are not encrypted for the new key yet
+func (r *KeyRotationReconciler) listPendingObjects(
+	ctx context.Context,
+	keyRotation *gitopssecretsnappcloudiov1alpha1.KeyRotation,
+	oldKey *gitopssecretsnappcloudiov1alpha1.GPGKey,
+	newKey *gitopssecretsnappcloudiov1alpha1.GPGKey,
+) (*pendingObjects, error) {
+	oldKeyName := client.ObjectKeyFromObject(oldKey)
+	pending := &pendingObjects{}
+	kinds := []struct {
+		list    client.ObjectList
+		pending *[]string
+	}{
+		{&gitopssecretsnappcloudiov1alpha1.SopsSecretList{}, &pending.sopsSecrets},
+		{&gitopssecretsnappcloudiov1alpha1.SopsFileList{}, &pending.sopsFiles},
+		{&gitopssecretsnappcloudiov1alpha1.SopsManifestList{}, &pending.sopsManifests},
+	}
+	for _, kind := range kinds {
+		referrers, err := listGPGKeyReferrers(ctx, r.Client, kind.list, oldKeyName)
+		if err != nil {
+			return nil, err
+		}
+		for _, referrer := range referrers {
+			ref, _ := gpgKeyReferenceTo(referrer, oldKeyName)
+			granted, err := gitopssecretsnappcloudiov1alpha1.IsGPGKeyReferenceGranted(ctx, r.Client, referrer.GetNamespace(), ref)
+			if err != nil {
+				return nil, err
+			}
+			if !granted || !pendingReencryption(referrer, oldKey.Status.Fingerprint, newKey.Status.Fingerprint) {
+				continue
+			}
+			name := referrer.GetName()
+			if referrer.GetNamespace() != keyRotation.Namespace {
+				name = referrer.GetNamespace() + "/" + name
+			}
+			*kind.pending = append(*kind.pending, name)
+		}
+		sort.Strings(*kind.pending)
+	}
+	return pending, nil
+}
+
+// pendingReencryption reports whether a sops payload of obj is encrypted for
+// the old fingerprint but not for the new one. The file of a sourceRef isn't
+// fetched, so it is pending as long as the old key is referenced, the same as
+// a payload whose sops metadata can't be read.
+func pendingReencryption(obj client.Object, oldFingerprint string, newFingerprint string) bool {
+	var recipients [][]string
+	addDocument := func(format string, data string) bool {
+		fingerprints, err := sopsDocumentFingerprints(format, []byte(data))
+		recipients = append(recipients, fingerprints)
+		return err == nil
+	}
+	switch typed := obj.(type) {
+	case *gitopssecretsnappcloudiov1alpha1.SopsSecret:
+		if typed.Spec.SourceRef != nil {
+			return true
+		}
+		if typed.Sops.Encrypted() {
+			var fingerprints []string
+			for _, pgpItem := range typed.Sops.PgpKeys() {
+				fingerprints = append(fingerprints, pgpItem.FingerPrint)
+			}
+			recipients = append(recipients, fingerprints)
+		}
+		if document := typed.Spec.Document; document != nil && !addDocument(document.Format, document.Data) {
+			return true
+		}
+	case *gitopssecretsnappcloudiov1alpha1.SopsFile:
+		if !addDocument(typed.Spec.Format, typed.Spec.Data) {
+			return true
+		}
+	case *gitopssecretsnappcloudiov1alpha1.SopsManifest:
+		if !addDocument(typed.Spec.Format, typed.Spec.Data) {
+			return true
+		}
+	}
+
+	for _, fingerprints := range recipients {
+		encryptedForOldKey, encryptedForNewKey := false, false
+		for _, fingerprint := range fingerprints {
+			encryptedForOldKey = encryptedForOldKey || fingerprintsMatch(fingerprint, oldFingerprint)
+			encryptedForNewKey = encryptedForNewKey || fingerprintsMatch(fingerprint, newFingerprint)
+		}
+		if encryptedForOldKey && !encryptedForNewKey {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *KeyRotationReconciler) finalizeKeyRotation(
+	ctx context.Context,
+	keyRotation *gitopssecretsnappcloudiov1alpha1.KeyRotation,
+) error {
+	if err := r.releaseOldKey(ctx, keyRotation); err != nil {
+		return err
+	}
+	if controllerutil.RemoveFinalizer(keyRotation, gitopssecretsnappcloudiov1alpha1.KeyRotationFinalizer) {
+		return r.Update(ctx, keyRotation)
+	}
+	return nil
+}
+
+// releaseOldKey removes the finalizer from the old GPGKey, unless another
+// rotation of the same key still has pending SopsSecrets
+func (r *KeyRotationReconciler) releaseOldKey(
+	ctx context.Context,
+	keyRotation *gitopssecretsnappcloudiov1alpha1.KeyRotation,
+) error {
+	keyRotations := &gitopssecretsnappcloudiov1alpha1.KeyRotationList{}
+	if err := r.List(ctx, keyRotations, client.InNamespace(keyRotation.Namespace)); err != nil {
+		return err
+	}
+	for _, other := range keyRotations.Items {
+		if other.Name != keyRotation.Name &&
+			other.DeletionTimestamp.IsZero() &&
+			other.Spec.OldKeyRef == keyRotation.Spec.OldKeyRef &&
+			other.Status.Phase == gitopssecretsnappcloudiov1alpha1.KeyRotationPhaseInProgress {
+			return nil
+		}
+	}
+
+	oldKey := &gitopssecretsnappcloudiov1alpha1.GPGKey{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: keyRotation.Namespace, Name: keyRotation.Spec.OldKeyRef}, oldKey)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if controllerutil.RemoveFinalizer(oldKey, gitopssecretsnappcloudiov1alpha1.KeyRotationFinalizer) {
+		return r.Update(ctx, oldKey)
+	}
+	return nil
+}
+
+// fingerprintsMatch compares two pgp fingerprints of 40 hex digits, allowing
+// one of them to be a long key id of 16, the last digits of the fingerprint.
+// Shorter ids collide with unrelated keys, so they never match.
+func fingerprintsMatch(a, b string) bool {
+	a = strings.ToUpper(strings.ReplaceAll(a, " ", ""))
+	b = strings.ToUpper(strings.ReplaceAll(b, " ", ""))
+	if !isFingerprintOrLongKeyID(a) || !isFingerprintOrLongKeyID(b) {
+		return false
+	}
+	if len(a) == len(b) {
+		return a == b
+	}
+	return strings.HasSuffix(a, b) || strings.HasSuffix(b, a)
+}
+
+// isFingerprintOrLongKeyID checks for a v4 fingerprint or a long key id
+func isFingerprintOrLongKeyID(id string) bool {
+	if len(id) != 40 && len(id) != 16 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *KeyRotationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&gitopssecretsnappcloudiov1alpha1.KeyRotation{}).
+		Watches(&gitopssecretsnappcloudiov1alpha1.SopsSecret{}, handler.EnqueueRequestsFromMapFunc(r.keyRotationsOfReferencedNamespaces)).
+		Watches(&gitopssecretsnappcloudiov1alpha1.SopsFile{}, handler.EnqueueRequestsFromMapFunc(r.keyRotationsOfReferencedNamespaces)).
+		Watches(&gitopssecretsnappcloudiov1alpha1.SopsManifest{}, handler.EnqueueRequestsFromMapFunc(r.keyRotationsOfReferencedNamespaces)).
+		Watches(&gitopssecretsnappcloudiov1alpha1.GPGKey{}, handler.EnqueueRequestsFromMapFunc(r.keyRotationsInNamespace)).
+		Watches(&gitopssecretsnappcloudiov1alpha1.GPGKeyGrant{}, handler.EnqueueRequestsFromMapFunc(r.keyRotationsInNamespace)).
+		Complete(r)
+}
+
+// keyRotationsOfReferencedNamespaces enqueues every KeyRotation of the
+// namespaces of the GPGKeys referenced by obj
+func (r *KeyRotationReconciler) keyRotationsOfReferencedNamespaces(ctx context.Context, obj client.Object) []reconcile.Request {
+	namespaces := map[string]bool{}
+	for _, ref := range gpgKeyReferences(obj) {
+		namespace := ref.Namespace
+		if namespace == "" {
+			namespace = obj.GetNamespace()
+		}
+		namespaces[namespace] = true
+	}
+	var requests []reconcile.Request
+	for namespace := range namespaces {
+		requests = append(requests, r.listKeyRotationRequests(ctx, namespace)...)
+	}
+	return requests
+}
+
+// keyRotationsInNamespace enqueues every KeyRotation of the namespace of obj
+func (r *KeyRotationReconciler) keyRotationsInNamespace(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.listKeyRotationRequests(ctx, obj.GetNamespace())
+}
+
+func (r *KeyRotationReconciler) listKeyRotationRequests(ctx context.Context, namespace string) []reconcile.Request {
+	keyRotations := &gitopssecretsnappcloudiov1alpha1.KeyRotationList{}
+	if err := r.List(ctx, keyRotations, client.InNamespace(namespace)); err != nil {
+		r.Log.Info("Couldn't list keyrotations", "namespace", namespace, "error", err)
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(keyRotations.Items))
+	for _, keyRotation := range keyRotations.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: keyRotation.Namespace, Name: keyRotation.Name},
+		})
+	}
+	return requests
+}
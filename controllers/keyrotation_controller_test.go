@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+)
+
+var _ = Describe("KeyRotation fingerprint matching", func() {
+	const fingerprint = "32B974509BC4B9DD570AB0E8067EBF5DA6F0220A"
+
+	It("Should match the same fingerprint regardless of case and spacing", func() {
+		Expect(fingerprintsMatch("32b9 7450 9bc4 b9dd 570a b0e8 067e bf5d a6f0 220a", fingerprint)).To(BeTrue())
+	})
+
+	It("Should match a long key id against the full fingerprint", func() {
+		Expect(fingerprintsMatch("067EBF5DA6F0220A", fingerprint)).To(BeTrue())
+	})
+
+	It("Should not match a different or empty fingerprint", func() {
+		Expect(fingerprintsMatch("FEA7B76B81AF174D06AB8886421D1DBA0B9F966E", fingerprint)).To(BeFalse())
+		Expect(fingerprintsMatch("", fingerprint)).To(BeFalse())
+	})
+
+	It("Should not match short key ids or long key ids of each other", func() {
+		Expect(fingerprintsMatch("A6F0220A", fingerprint)).To(BeFalse())
+		Expect(fingerprintsMatch("0220A", fingerprint)).To(BeFalse())
+		Expect(fingerprintsMatch("067EBF5DA6F0220A", "167EBF5DA6F0220A")).To(BeFalse())
+		Expect(fingerprintsMatch("067EBF5DA6F0220A", "067ebf5da6f0220a")).To(BeTrue())
+	})
+})
+
+var _ = Describe("KeyRotation dependents", func() {
+	const (
+		oldFingerprint = "32B974509BC4B9DD570AB0E8067EBF5DA6F0220A"
+		newFingerprint = "FEA7B76B81AF174D06AB8886421D1DBA0B9F966E"
+	)
+
+	It("Should resolve references without namespace to the namespace of the object", func() {
+		sopsFile := &gitopssecretsnappcloudiov1alpha1.SopsFile{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "example"},
+			Spec: gitopssecretsnappcloudiov1alpha1.SopsFileSpec{
+				GPGKeyRefs: []gitopssecretsnappcloudiov1alpha1.GPGKeyReference{{Name: "local"}, {Namespace: "shared", Name: "old"}},
+			},
+		}
+		_, ok := gpgKeyReferenceTo(sopsFile, types.NamespacedName{Namespace: "team-a", Name: "local"})
+		Expect(ok).To(BeTrue())
+		_, ok = gpgKeyReferenceTo(sopsFile, types.NamespacedName{Namespace: "shared", Name: "old"})
+		Expect(ok).To(BeTrue())
+		_, ok = gpgKeyReferenceTo(sopsFile, types.NamespacedName{Namespace: "team-a", Name: "old"})
+		Expect(ok).To(BeFalse())
+	})
+
+	It("Should read the recipients of whole sops documents", func() {
+		content, err := ioutil.ReadFile(filepath.Join("..", "config", "pgp-test-key", "example.enc.yaml"))
+		Expect(err).To(BeNil())
+		sopsFile := &gitopssecretsnappcloudiov1alpha1.SopsFile{
+			Spec: gitopssecretsnappcloudiov1alpha1.SopsFileSpec{Format: "yaml", Data: string(content)},
+		}
+		Expect(pendingReencryption(sopsFile, oldFingerprint, newFingerprint)).To(BeTrue())
+		Expect(pendingReencryption(sopsFile, oldFingerprint, oldFingerprint)).To(BeFalse())
+		Expect(pendingReencryption(sopsFile, newFingerprint, oldFingerprint)).To(BeFalse())
+	})
+
+	It("Should keep a sourceRef pending while the old key is referenced", func() {
+		sopsSecret := &gitopssecretsnappcloudiov1alpha1.SopsSecret{
+			Spec: gitopssecretsnappcloudiov1alpha1.SopsSecretSpec{
+				SourceRef: &gitopssecretsnappcloudiov1alpha1.SopsSourceReference{Format: "yaml"},
+			},
+		}
+		Expect(pendingReencryption(sopsSecret, oldFingerprint, newFingerprint)).To(BeTrue())
+	})
+})
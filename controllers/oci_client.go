@@ -0,0 +1,308 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+)
+
+const (
+	ociManifestMediaType    = "application/vnd.oci.image.manifest.v1+json"
+	dockerManifestMediaType = "application/vnd.docker.distribution.manifest.v2+json"
+	ociTitleAnnotation      = "org.opencontainers.image.title"
+
+	// maxSourceSize bounds the encrypted files fetched for spec.sourceRef,
+	// their plaintext has to fit a Secret anyway
+	maxSourceSize = 4 << 20
+)
+
+// ociHTTPClient is shared by the pulls of every SopsSecret
+var ociHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ociClient pulls a single file of an OCI artifact through the registry
+// http api, with basic auth when credentials are set or with the bearer
+// token the registry challenges for, e.g. Harbor, GHCR or Docker Hub
+type ociClient struct {
+	httpClient *http.Client
+	username   string
+	password   string
+	// token answers the last bearer challenge, reused by the next requests
+	token string
+}
+
+// errOCINotFound when the registry doesn't know the artifact or its layer
+type errOCINotFound struct {
+	url string
+}
+
+func (e *errOCINotFound) Error() string {
+	return fmt.Sprintf("%s not found in the registry", e.url)
+}
+
+// pull returns the file of the artifact and the digest of its manifest
+func (c *ociClient) pull(ctx context.Context, source *gitopssecretsnappcloudiov1alpha1.OCISource) ([]byte, string, error) {
+	registry, name, err := splitOCIRepository(source.Repository)
+	if err != nil {
+		return nil, "", err
+	}
+	scheme := "https"
+	if source.Insecure {
+		scheme = "http"
+	}
+	reference := source.Reference
+	if reference == "" {
+		reference = "latest"
+	}
+	baseURL := fmt.Sprintf("%s://%s/v2/%s", scheme, registry, name)
+
+	body, manifestDigest, err := c.get(ctx, baseURL+"/manifests/"+reference, ociManifestMediaType+", "+dockerManifestMediaType)
+	if err != nil {
+		return nil, "", err
+	}
+	if manifestDigest == "" {
+		manifestDigest = sha256Digest(body)
+	}
+	if strings.HasPrefix(reference, "sha256:") && sha256Digest(body) != reference {
+		return nil, "", fmt.Errorf("manifest of %s doesn't match its digest", source.Repository)
+	}
+
+	manifest := &ociManifest{}
+	if err := json.Unmarshal(body, manifest); err != nil {
+		return nil, "", fmt.Errorf("manifest of %s: %w", source.Repository, err)
+	}
+	layer, err := manifest.layer(source.File)
+	if err != nil {
+		return nil, "", fmt.Errorf("manifest of %s: %w", source.Repository, err)
+	}
+
+	blob, _, err := c.get(ctx, baseURL+"/blobs/"+layer.Digest, "")
+	if err != nil {
+		return nil, "", err
+	}
+	if sha256Digest(blob) != layer.Digest {
+		return nil, "", fmt.Errorf("layer %s of %s doesn't match its digest", layer.Digest, source.Repository)
+	}
+	return blob, manifestDigest, nil
+}
+
+// layer returns the layer titled file, or the only layer when file is empty
+func (m *ociManifest) layer(file string) (*ociDescriptor, error) {
+	if file == "" {
+		if len(m.Layers) != 1 {
+			return nil, fmt.Errorf("has %d layers, set the file to pull", len(m.Layers))
+		}
+		return &m.Layers[0], nil
+	}
+	for i := range m.Layers {
+		if m.Layers[i].Annotations[ociTitleAnnotation] == file {
+			return &m.Layers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("has no layer titled %s", file)
+}
+
+func (c *ociClient) get(ctx context.Context, url string, accept string) ([]byte, string, error) {
+	resp, err := c.do(ctx, url, accept)
+	if err != nil {
+		return nil, "", err
+	}
+	if challenge := resp.Header.Get("WWW-Authenticate"); resp.StatusCode == http.StatusUnauthorized && isBearerChallenge(challenge) {
+		resp.Body.Close()
+		if c.token, err = c.fetchToken(ctx, challenge); err != nil {
+			return nil, "", fmt.Errorf("GET %s: %w", url, err)
+		}
+		if resp, err = c.do(ctx, url, accept); err != nil {
+			return nil, "", err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, "", &errOCINotFound{url: url}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxSourceSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(body) > maxSourceSize {
+		return nil, "", fmt.Errorf("GET %s: larger than %d bytes", url, maxSourceSize)
+	}
+	return body, resp.Header.Get("Docker-Content-Digest"), nil
+}
+
+// do sends a GET with the bearer token of the last challenge, or with basic
+// auth until the registry challenges for a token
+func (c *ociClient) do(ctx context.Context, url string, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	} else if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	return c.httpClient.Do(req)
+}
+
+// fetchToken answers a bearer challenge of the registry, e.g.
+// Bearer realm="https://ghcr.io/token",service="ghcr.io",scope="repository:team/secrets:pull"
+// with a token of its realm, anonymous when no credentials are set
+func (c *ociClient) fetchToken(ctx context.Context, challenge string) (string, error) {
+	params := parseBearerChallenge(challenge)
+	realm, err := neturl.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("bearer challenge has no valid realm: %s", challenge)
+	}
+	query := realm.Query()
+	for _, param := range []string{"service", "scope"} {
+		if value := params[param]; value != "" {
+			query.Set(param, value)
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.username != "" || c.password != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token of %s: %s", realm.Host, resp.Status)
+	}
+
+	// distribution token auth names it token, oauth2 access_token
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSourceSize)).Decode(&token); err != nil {
+		return "", fmt.Errorf("token of %s: %w", realm.Host, err)
+	}
+	if token.Token != "" {
+		return token.Token, nil
+	}
+	if token.AccessToken != "" {
+		return token.AccessToken, nil
+	}
+	return "", fmt.Errorf("token of %s: empty token", realm.Host)
+}
+
+func isBearerChallenge(challenge string) bool {
+	return len(challenge) > len("Bearer ") && strings.EqualFold(challenge[:len("Bearer ")], "Bearer ")
+}
+
+// parseBearerChallenge returns the parameters of a WWW-Authenticate bearer
+// challenge, whose quoted values may hold commas, e.g. a scope of two actions
+func parseBearerChallenge(challenge string) map[string]string {
+	params := map[string]string{}
+	rest := strings.TrimSpace(challenge[len("Bearer "):])
+	for rest != "" {
+		parts := strings.SplitN(rest, "=", 2)
+		if len(parts) != 2 {
+			break
+		}
+		name, value := strings.ToLower(strings.TrimSpace(parts[0])), strings.TrimSpace(parts[1])
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				break
+			}
+			params[name] = value[1 : end+1]
+			rest = value[end+2:]
+		} else {
+			end := strings.Index(value, ",")
+			if end < 0 {
+				end = len(value)
+			}
+			params[name] = strings.TrimSpace(value[:end])
+			rest = value[end:]
+		}
+		rest = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rest), ","))
+	}
+	return params
+}
+
+// splitOCIRepository splits registry.example.com:5000/team/secrets into its
+// registry host and repository name
+func splitOCIRepository(repository string) (string, string, error) {
+	repository = strings.TrimPrefix(strings.TrimPrefix(repository, "oci://"), "/")
+	parts := strings.SplitN(repository, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("repository %s has no registry host", repository)
+	}
+	return parts[0], parts[1], nil
+}
+
+// registryCredentials reads the username and password of registry from a
+// kubernetes.io/dockerconfigjson Secret
+func registryCredentials(secret *corev1.Secret, registry string) (string, string, error) {
+	dockerConfig := struct {
+		Auths map[string]struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Auth     string `json:"auth"`
+		} `json:"auths"`
+	}{}
+	if err := json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &dockerConfig); err != nil {
+		return "", "", fmt.Errorf("pull secret %s: %w", secret.Name, err)
+	}
+	auth, ok := dockerConfig.Auths[registry]
+	if !ok {
+		return "", "", fmt.Errorf("pull secret %s has no credentials for %s", secret.Name, registry)
+	}
+	if auth.Auth == "" {
+		return auth.Username, auth.Password, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+	if err != nil {
+		return "", "", fmt.Errorf("pull secret %s: %w", secret.Name, err)
+	}
+	credentials := strings.SplitN(string(decoded), ":", 2)
+	if len(credentials) != 2 {
+		return "", "", fmt.Errorf("pull secret %s has a malformed auth for %s", secret.Name, registry)
+	}
+	return credentials[0], credentials[1], nil
+}
+
+func sha256Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+)
+
+var _ = Describe("OCI client", func() {
+	file := []byte("user: ENC[AES256_GCM,data:abc,type:str]\n")
+	manifest, _ := json.Marshal(ociManifest{
+		MediaType: ociManifestMediaType,
+		Layers: []ociDescriptor{{
+			MediaType:   "application/vnd.oci.image.layer.v1.tar",
+			Digest:      sha256Digest(file),
+			Size:        int64(len(file)),
+			Annotations: map[string]string{ociTitleAnnotation: "secrets.enc.yaml"},
+		}},
+	})
+
+	var registry *httptest.Server
+	BeforeEach(func() {
+		registry = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if username, password, _ := req.BasicAuth(); username != "puller" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			switch req.URL.Path {
+			case "/v2/team/secrets/manifests/v1":
+				_, _ = w.Write(manifest)
+			case "/v2/team/secrets/blobs/" + sha256Digest(file):
+				_, _ = w.Write(file)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+	})
+	AfterEach(func() {
+		registry.Close()
+	})
+
+	source := func(reference, file string) *gitopssecretsnappcloudiov1alpha1.OCISource {
+		return &gitopssecretsnappcloudiov1alpha1.OCISource{
+			Repository: strings.TrimPrefix(registry.URL, "http://") + "/team/secrets",
+			Reference:  reference,
+			File:       file,
+			Insecure:   true,
+		}
+	}
+	client := func() *ociClient {
+		return &ociClient{httpClient: registry.Client(), username: "puller", password: "secret"}
+	}
+
+	It("Should pull the file of a single layer artifact", func() {
+		data, digest, err := client().pull(context.Background(), source("v1", ""))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal(file))
+		Expect(digest).To(Equal(sha256Digest(manifest)))
+	})
+
+	It("Should report a missing layer title", func() {
+		_, _, err := client().pull(context.Background(), source("v1", "other.enc.yaml"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should report a missing tag as not found", func() {
+		_, _, err := client().pull(context.Background(), source("v2", ""))
+		Expect(err).To(BeAssignableToTypeOf(&errOCINotFound{}))
+	})
+
+	It("Should answer the bearer challenge of a token registry", func() {
+		var tokenRequests []string
+		var tokenRegistry *httptest.Server
+		tokenRegistry = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/token" {
+				tokenRequests = append(tokenRequests, req.URL.RawQuery)
+				if username, password, _ := req.BasicAuth(); username != "puller" || password != "secret" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				_, _ = w.Write([]byte(`{"access_token":"pull-token"}`))
+				return
+			}
+			if req.Header.Get("Authorization") != "Bearer pull-token" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="`+tokenRegistry.URL+`/token",service="registry.test",scope="repository:team/secrets:pull"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			switch req.URL.Path {
+			case "/v2/team/secrets/manifests/v1":
+				_, _ = w.Write(manifest)
+			case "/v2/team/secrets/blobs/" + sha256Digest(file):
+				_, _ = w.Write(file)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer tokenRegistry.Close()
+
+		registryClient := &ociClient{httpClient: tokenRegistry.Client(), username: "puller", password: "secret"}
+		data, _, err := registryClient.pull(context.Background(), &gitopssecretsnappcloudiov1alpha1.OCISource{
+			Repository: strings.TrimPrefix(tokenRegistry.URL, "http://") + "/team/secrets",
+			Reference:  "v1",
+			Insecure:   true,
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(Equal(file))
+		// the token of the manifest is reused for the blob
+		Expect(tokenRequests).To(Equal([]string{"scope=repository%3Ateam%2Fsecrets%3Apull&service=registry.test"}))
+	})
+
+	It("Should parse bearer challenges with quoted commas", func() {
+		challenge := `Bearer realm="https://auth.example.com/token",service=registry.example.com, scope="repository:team/secrets:pull,push"`
+		Expect(isBearerChallenge(challenge)).To(BeTrue())
+		Expect(isBearerChallenge(`Basic realm="registry"`)).To(BeFalse())
+		Expect(parseBearerChallenge(challenge)).To(Equal(map[string]string{
+			"realm":   "https://auth.example.com/token",
+			"service": "registry.example.com",
+			"scope":   "repository:team/secrets:pull,push",
+		}))
+	})
+})
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	"github.com/snapp-incubator/sops-operator/lang"
+)
+
+// reconcileErrorClass decides how a failed reconciliation is retried
+type reconcileErrorClass int
+
+const (
+	// errorClassTransient is retried with the exponential backoff of the
+	// controller rate limiter, e.g. an apiserver timeout or a conflict
+	errorClassTransient reconcileErrorClass = iota
+	// errorClassMissingReference polls every RequeueAfter minutes, as the
+	// missing object, grant or annotation is not always watched
+	errorClassMissingReference
+	// errorClassCrypto is not retried, as it only goes away with a change of
+	// the object or of its GPGKeys, which are both watched. GPGKeys not
+	// imported yet, e.g. after a restart of the operator, are missing
+	// references instead.
+	errorClassCrypto
+)
+
+// specChangedPredicate passes the changes of the spec, labels and annotations
+// of the reconciled objects, but not their status updates, which can't fix an
+// error and would retry crypto errors forever
+var specChangedPredicate = predicate.Or(
+	predicate.GenerationChangedPredicate{},
+	predicate.AnnotationChangedPredicate{},
+	predicate.LabelChangedPredicate{},
+)
+
+// reconcileError tags an error with its class
+type reconcileError struct {
+	class reconcileErrorClass
+	err   error
+}
+
+func (e *reconcileError) Error() string {
+	return e.err.Error()
+}
+
+func (e *reconcileError) Unwrap() error {
+	return e.err
+}
+
+// missingReferenceError tags err as a missing reference, unless it is an
+// apiserver failure other than not found
+func missingReferenceError(err error) error {
+	if !apierrors.IsNotFound(err) && apierrors.ReasonForError(err) != "" {
+		return err
+	}
+	return &reconcileError{class: errorClassMissingReference, err: err}
+}
+
+// cryptoError tags err as a failure to decrypt or verify the SopsSecret, or
+// to use its decrypted content
+func cryptoError(err error) error {
+	return &reconcileError{class: errorClassCrypto, err: err}
+}
+
+func classifyError(err error) reconcileErrorClass {
+	var classified *reconcileError
+	if errors.As(err, &classified) {
+		return classified.class
+	}
+	return errorClassTransient
+}
+
+// requeueOnError turns a failed step of the SopsSecret reconciliation into
+// the result matching its error class
+func (r *SopsSecretReconciler) requeueOnError(req ctrl.Request, err error) (ctrl.Result, error) {
+	return resultForError(r.Log, r.RequeueAfter, "sopssecret", req, err)
+}
+
+// resultForError returns the result matching the class of err for an object
+// of kind, which waits requeueAfter minutes for missing references
+func resultForError(log logr.Logger, requeueAfter int64, kind string, req ctrl.Request, err error) (ctrl.Result, error) {
+	switch classifyError(err) {
+	case errorClassMissingReference:
+		return ctrl.Result{RequeueAfter: time.Duration(requeueAfter) * time.Minute}, nil
+	case errorClassCrypto:
+		log.Info("Waiting for a change of the "+kind+" or its gpgkeys", kind, req.NamespacedName, "error", err)
+		return ctrl.Result{}, nil
+	default:
+		return ctrl.Result{}, err
+	}
+}
+
+// healthReporter is a kind reporting its health in its status
+type healthReporter interface {
+	client.Object
+	SetHealth(health, message string)
+}
+
+// reportFailure records message as the unhealthy status of obj, an object
+// of kind, and returns the result matching the class of err
+func reportFailure(
+	ctx context.Context,
+	c client.Client,
+	log logr.Logger,
+	requeueAfter int64,
+	kind string,
+	req ctrl.Request,
+	obj healthReporter,
+	message string,
+	err error,
+) (ctrl.Result, error) {
+	obj.SetHealth(lang.SopsUnHealthyStatus, message)
+	_ = c.Status().Update(ctx, obj)
+	return resultForError(log, requeueAfter, kind, req, err)
+}
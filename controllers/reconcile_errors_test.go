@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Reconcile error classes", func() {
+	resource := schema.GroupResource{Resource: "secrets"}
+	reconciler := &SopsSecretReconciler{Log: ctrl.Log, RequeueAfter: 5}
+
+	It("Should return transient errors to the rate limiter", func() {
+		err := apierrors.NewServerTimeout(resource, "get", 1)
+		result, returned := reconciler.requeueOnError(ctrl.Request{}, missingReferenceError(err))
+		Expect(returned).To(Equal(err))
+		Expect(result).To(Equal(ctrl.Result{}))
+	})
+
+	It("Should return conflicts to the rate limiter", func() {
+		err := apierrors.NewConflict(resource, "example-secret", fmt.Errorf("modified"))
+		result, returned := reconciler.requeueOnError(ctrl.Request{}, err)
+		Expect(returned).To(Equal(err))
+		Expect(result).To(Equal(ctrl.Result{}))
+	})
+
+	It("Should poll for missing references", func() {
+		err := missingReferenceError(apierrors.NewNotFound(resource, "example-secret"))
+		result, returned := reconciler.requeueOnError(ctrl.Request{}, err)
+		Expect(returned).NotTo(HaveOccurred())
+		Expect(result).To(Equal(ctrl.Result{RequeueAfter: 5 * time.Minute}))
+	})
+
+	It("Should wait for a change instead of retrying crypto errors", func() {
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "crypto-error"}}
+		result, returned := reconciler.requeueOnError(req, cryptoError(fmt.Errorf("wrong key")))
+		Expect(returned).NotTo(HaveOccurred())
+		Expect(result).To(Equal(ctrl.Result{}))
+	})
+})
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+)
+
+// secretDataChecksum returns a sha256 checksum of the secret data, which
+// doesn't depend on the order of the keys
+func secretDataChecksum(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%d:%s%d:", len(key), key, len(data[key]))
+		hash.Write(data[key])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// rolloutWorkloads patches the checksum annotation onto the pod templates of
+// the workloads listed in rolloutOnChange, and the discovered ones if asked to
+func (r *SopsSecretReconciler) rolloutWorkloads(
+	ctx context.Context,
+	sopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret,
+	secretName string,
+	checksum string,
+) error {
+	rolloutOnChange := sopsSecret.Spec.RolloutOnChange
+	if rolloutOnChange == nil {
+		return nil
+	}
+
+	workloads := map[gitopssecretsnappcloudiov1alpha1.WorkloadReference]bool{}
+	for _, workload := range rolloutOnChange.Workloads {
+		workloads[workload] = true
+	}
+	if rolloutOnChange.AutoDiscover {
+		discovered, err := r.discoverWorkloads(ctx, sopsSecret.Namespace, secretName)
+		if err != nil {
+			return err
+		}
+		for _, workload := range discovered {
+			workloads[workload] = true
+		}
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{
+						gitopssecretsnappcloudiov1alpha1.SopsSecretChecksumAnnotation: checksum,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	for workload := range workloads {
+		object := newWorkloadObject(workload.Kind)
+		if object == nil {
+			continue
+		}
+		err := r.Get(ctx, types.NamespacedName{Namespace: sopsSecret.Namespace, Name: workload.Name}, object)
+		if errors.IsNotFound(err) {
+			r.Log.Info("Workload to roll out not found", "kind", workload.Kind, "name", workload.Name, "namespace", sopsSecret.Namespace)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if podTemplateOf(object).Annotations[gitopssecretsnappcloudiov1alpha1.SopsSecretChecksumAnnotation] == checksum {
+			continue
+		}
+		if err := r.Patch(ctx, object, client.RawPatch(types.MergePatchType, patch)); err != nil {
+			return err
+		}
+		r.Log.Info("Rolling out workload", "kind", workload.Kind, "name", workload.Name, "namespace", sopsSecret.Namespace)
+	}
+	return nil
+}
+
+// discoverWorkloads finds the workloads of a namespace whose pods use the secret
+func (r *SopsSecretReconciler) discoverWorkloads(
+	ctx context.Context,
+	namespace string,
+	secretName string,
+) ([]gitopssecretsnappcloudiov1alpha1.WorkloadReference, error) {
+	var workloads []gitopssecretsnappcloudiov1alpha1.WorkloadReference
+
+	deployments := &appsv1.DeploymentList{}
+	if err := r.List(ctx, deployments, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for _, deployment := range deployments.Items {
+		if podSpecUsesSecret(&deployment.Spec.Template.Spec, secretName) {
+			workloads = append(workloads, gitopssecretsnappcloudiov1alpha1.WorkloadReference{Kind: "Deployment", Name: deployment.Name})
+		}
+	}
+
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := r.List(ctx, statefulSets, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for _, statefulSet := range statefulSets.Items {
+		if podSpecUsesSecret(&statefulSet.Spec.Template.Spec, secretName) {
+			workloads = append(workloads, gitopssecretsnappcloudiov1alpha1.WorkloadReference{Kind: "StatefulSet", Name: statefulSet.Name})
+		}
+	}
+
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := r.List(ctx, daemonSets, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for _, daemonSet := range daemonSets.Items {
+		if podSpecUsesSecret(&daemonSet.Spec.Template.Spec, secretName) {
+			workloads = append(workloads, gitopssecretsnappcloudiov1alpha1.WorkloadReference{Kind: "DaemonSet", Name: daemonSet.Name})
+		}
+	}
+	return workloads, nil
+}
+
+// podSpecUsesSecret checks the env, envFrom and volumes of a pod for the secret
+func podSpecUsesSecret(podSpec *corev1.PodSpec, secretName string) bool {
+	for _, volume := range podSpec.Volumes {
+		if volume.Secret != nil && volume.Secret.SecretName == secretName {
+			return true
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil && source.Secret.Name == secretName {
+					return true
+				}
+			}
+		}
+	}
+
+	containers := append([]corev1.Container{}, podSpec.InitContainers...)
+	containers = append(containers, podSpec.Containers...)
+	for _, container := range containers {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.SecretRef != nil && envFrom.SecretRef.Name == secretName {
+				return true
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil && env.ValueFrom.SecretKeyRef.Name == secretName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func newWorkloadObject(kind string) client.Object {
+	switch kind {
+	case "Deployment":
+		return &appsv1.Deployment{}
+	case "StatefulSet":
+		return &appsv1.StatefulSet{}
+	case "DaemonSet":
+		return &appsv1.DaemonSet{}
+	}
+	return nil
+}
+
+func podTemplateOf(object client.Object) *metav1.ObjectMeta {
+	switch workload := object.(type) {
+	case *appsv1.Deployment:
+		return &workload.Spec.Template.ObjectMeta
+	case *appsv1.StatefulSet:
+		return &workload.Spec.Template.ObjectMeta
+	case *appsv1.DaemonSet:
+		return &workload.Spec.Template.ObjectMeta
+	}
+	return &metav1.ObjectMeta{}
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+	"github.com/snapp-incubator/sops-operator/lang"
+)
+
+var _ = Describe("Rollout on secret change", func() {
+	It("Should compute the same checksum regardless of the key order", func() {
+		Expect(secretDataChecksum(map[string][]byte{"a": []byte("1"), "b": []byte("2")})).
+			To(Equal(secretDataChecksum(map[string][]byte{"b": []byte("2"), "a": []byte("1")})))
+		Expect(secretDataChecksum(map[string][]byte{"a": []byte("1")})).
+			NotTo(Equal(secretDataChecksum(map[string][]byte{"a": []byte("2")})))
+	})
+
+	It("Should find the secret in env, envFrom and volumes", func() {
+		secretKeyRef := &corev1.PodSpec{Containers: []corev1.Container{{
+			Env: []corev1.EnvVar{{Name: "PASSWORD", ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "example-secret"}, Key: "password"},
+			}}},
+		}}}
+		envFrom := &corev1.PodSpec{InitContainers: []corev1.Container{{
+			EnvFrom: []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "example-secret"}}}},
+		}}}
+		volume := &corev1.PodSpec{Volumes: []corev1.Volume{{
+			Name:         "secret",
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "example-secret"}},
+		}}}
+
+		Expect(podSpecUsesSecret(secretKeyRef, "example-secret")).To(BeTrue())
+		Expect(podSpecUsesSecret(envFrom, "example-secret")).To(BeTrue())
+		Expect(podSpecUsesSecret(volume, "example-secret")).To(BeTrue())
+		Expect(podSpecUsesSecret(volume, "other-secret")).To(BeFalse())
+	})
+})
+
+var _ = Describe("Pending rollouts", func() {
+	newSopsSecret := func() *gitopssecretsnappcloudiov1alpha1.SopsSecret {
+		return &gitopssecretsnappcloudiov1alpha1.SopsSecret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example-secret"},
+			Spec: gitopssecretsnappcloudiov1alpha1.SopsSecretSpec{
+				RolloutOnChange: &gitopssecretsnappcloudiov1alpha1.RolloutOnChange{
+					Workloads: []gitopssecretsnappcloudiov1alpha1.WorkloadReference{{Kind: "Deployment", Name: "example"}},
+				},
+			},
+			Status: gitopssecretsnappcloudiov1alpha1.SopsSecretStatus{PendingRolloutChecksum: "outdated"},
+		}
+	}
+	kubeSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example-secret"},
+		Data:       map[string][]byte{"password": []byte("s3cr3t")},
+	}
+
+	It("Should keep the checksum and return the error of a failed rollout", func() {
+		failingClient := interceptor.NewClient(fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), interceptor.Funcs{
+			Get: func(context.Context, client.WithWatch, client.ObjectKey, client.Object, ...client.GetOption) error {
+				return fmt.Errorf("apiserver unavailable")
+			},
+		})
+		reconciler := &SopsSecretReconciler{Client: failingClient, Log: ctrl.Log}
+		sopsSecret := newSopsSecret()
+
+		Expect(reconciler.rolloutPendingWorkloads(context.Background(), ctrl.Request{}, sopsSecret, kubeSecret)).NotTo(Succeed())
+		Expect(sopsSecret.Status.PendingRolloutChecksum).To(Equal(secretDataChecksum(kubeSecret.Data)))
+		Expect(sopsSecret.Status.Message).To(Equal(lang.ErrSopsSecretRolloutFailed))
+	})
+
+	It("Should clear the checksum once the workloads are rolled out", func() {
+		reconciler := &SopsSecretReconciler{Client: fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), Log: ctrl.Log}
+		sopsSecret := newSopsSecret()
+
+		Expect(reconciler.rolloutPendingWorkloads(context.Background(), ctrl.Request{}, sopsSecret, kubeSecret)).To(Succeed())
+		Expect(sopsSecret.Status.PendingRolloutChecksum).To(BeEmpty())
+	})
+})
@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mozilla.org/sops/v3"
+	sopsdotenv "go.mozilla.org/sops/v3/stores/dotenv"
+	sopsjson "go.mozilla.org/sops/v3/stores/json"
+	sopsyaml "go.mozilla.org/sops/v3/stores/yaml"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+const (
+	secretFileFormatYAML       = "yaml"
+	secretFileFormatJSON       = "json"
+	secretFileFormatDotenv     = "dotenv"
+	secretFileFormatProperties = "properties"
+)
+
+// secretFileFormat picks the format of a file of spec.files by the extension of its name
+func secretFileFormat(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json":
+		return secretFileFormatJSON
+	case ".env":
+		return secretFileFormatDotenv
+	case ".properties":
+		return secretFileFormatProperties
+	default:
+		return secretFileFormatYAML
+	}
+}
+
+// renderSecretFiles serializes the decrypted files of a SopsSecret, one
+// child secret key per file
+func renderSecretFiles(files map[string]apiextensionsv1.JSON) (map[string]string, error) {
+	rendered := make(map[string]string, len(files))
+	for name, content := range files {
+		data, err := renderSecretFile(name, content)
+		if err != nil {
+			return nil, fmt.Errorf("files %s: %w", name, err)
+		}
+		rendered[name] = string(data)
+	}
+	return rendered, nil
+}
+
+func renderSecretFile(name string, content apiextensionsv1.JSON) ([]byte, error) {
+	format := secretFileFormat(name)
+	if format == secretFileFormatDotenv || format == secretFileFormatProperties {
+		return renderFlatSecretFile(format, content)
+	}
+
+	// the yaml store keeps the order and the types of the decrypted values,
+	// json being a subset of yaml
+	branches, err := (&sopsyaml.Store{}).LoadPlainFile(content.Raw)
+	if err != nil {
+		return nil, err
+	}
+	if format == secretFileFormatJSON {
+		return (&sopsjson.Store{}).EmitPlainFile(branches)
+	}
+	return (&sopsyaml.Store{}).EmitPlainFile(branches)
+}
+
+// renderFlatSecretFile writes a file of key value lines. Properties files
+// flatten nested maps and lists into dotted keys, dotenv files must be flat.
+func renderFlatSecretFile(format string, content apiextensionsv1.JSON) ([]byte, error) {
+	var tree map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(content.Raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+	if format == secretFileFormatDotenv {
+		for key, value := range tree {
+			switch value.(type) {
+			case map[string]interface{}, []interface{}:
+				return nil, fmt.Errorf("dotenv files can't have nested values: %s", key)
+			}
+		}
+	}
+
+	values := map[string]string{}
+	if err := flattenSecretFile(values, "", tree); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if format == secretFileFormatDotenv {
+		branch := sops.TreeBranch{}
+		for _, key := range keys {
+			branch = append(branch, sops.TreeItem{Key: key, Value: values[key]})
+		}
+		return (&sopsdotenv.Store{}).EmitPlainFile(sops.TreeBranches{branch})
+	}
+
+	buffer := bytes.Buffer{}
+	for _, key := range keys {
+		buffer.WriteString(escapeProperty(key, true) + "=" + escapeProperty(values[key], false) + "\n")
+	}
+	return buffer.Bytes(), nil
+}
+
+func flattenSecretFile(values map[string]string, prefix string, tree interface{}) error {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch typed := tree.(type) {
+	case map[string]interface{}:
+		for key, value := range typed {
+			if err := flattenSecretFile(values, join(key), value); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, value := range typed {
+			if err := flattenSecretFile(values, join(strconv.Itoa(i)), value); err != nil {
+				return err
+			}
+		}
+	case string:
+		values[prefix] = typed
+	case json.Number:
+		values[prefix] = typed.String()
+	case bool:
+		values[prefix] = strconv.FormatBool(typed)
+	case nil:
+		values[prefix] = ""
+	default:
+		return fmt.Errorf("unsupported value %v", typed)
+	}
+	return nil
+}
+
+// escapeProperty escapes a key or value of a java properties file
+func escapeProperty(s string, key bool) string {
+	var builder strings.Builder
+	for i, r := range s {
+		switch r {
+		case '\\':
+			builder.WriteString(`\\`)
+		case '\n':
+			builder.WriteString(`\n`)
+		case '\r':
+			builder.WriteString(`\r`)
+		case '\t':
+			builder.WriteString(`\t`)
+		case '=', ':', '#', '!', ' ':
+			if key || i == 0 {
+				builder.WriteRune('\\')
+			}
+			builder.WriteRune(r)
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
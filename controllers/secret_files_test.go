@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+var _ = Describe("Secret files", func() {
+	content := apiextensionsv1.JSON{Raw: []byte(`{"database":{"password":"p@ss word","port":5432},"debug":true}`)}
+
+	It("Should pick the format by the extension of the name", func() {
+		Expect(secretFileFormat("config.yaml")).To(Equal(secretFileFormatYAML))
+		Expect(secretFileFormat("config")).To(Equal(secretFileFormatYAML))
+		Expect(secretFileFormat("config.JSON")).To(Equal(secretFileFormatJSON))
+		Expect(secretFileFormat(".env")).To(Equal(secretFileFormatDotenv))
+		Expect(secretFileFormat("app.properties")).To(Equal(secretFileFormatProperties))
+	})
+
+	It("Should render yaml keeping the value types", func() {
+		data, err := renderSecretFile("config.yaml", content)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("database:\n    password: p@ss word\n    port: 5432\ndebug: true\n"))
+	})
+
+	It("Should render json", func() {
+		data, err := renderSecretFile("config.json", content)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(data).To(MatchJSON(content.Raw))
+	})
+
+	It("Should flatten properties into dotted keys", func() {
+		data, err := renderSecretFile("app.properties", content)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("database.password=p@ss word\ndatabase.port=5432\ndebug=true\n"))
+	})
+
+	It("Should render flat dotenv files and reject nested ones", func() {
+		data, err := renderSecretFile(".env", apiextensionsv1.JSON{Raw: []byte(`{"USER":"admin","PORT":5432}`)})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("PORT=5432\nUSER=admin\n"))
+
+		_, err = renderSecretFile(".env", content)
+		Expect(err).To(HaveOccurred())
+	})
+})
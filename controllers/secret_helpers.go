@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+
+	corev1 "k8s.io/api/core/v1"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+)
+
+// dockerConfigJSON is the content of the .dockerconfigjson key
+type dockerConfigJSON struct {
+	Auths map[string]dockerConfigAuth `json:"auths"`
+}
+
+type dockerConfigAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email,omitempty"`
+	Auth     string `json:"auth"`
+}
+
+// expandSecretHelpers returns the child secret entries of the decrypted
+// typed helpers of spec
+func expandSecretHelpers(spec *gitopssecretsnappcloudiov1alpha1.SopsSecretSpec) (map[string]string, error) {
+	entries := map[string]string{}
+	if helper := spec.DockerConfig; helper != nil {
+		dockerConfig, err := json.Marshal(dockerConfigJSON{
+			Auths: map[string]dockerConfigAuth{
+				helper.Registry: {
+					Username: helper.Username,
+					Password: helper.Password,
+					Email:    helper.Email,
+					Auth:     base64.StdEncoding.EncodeToString([]byte(helper.Username + ":" + helper.Password)),
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		entries[corev1.DockerConfigJsonKey] = string(dockerConfig)
+	}
+	if helper := spec.TLS; helper != nil {
+		entries[corev1.TLSCertKey] = helper.Certificate
+		entries[corev1.TLSPrivateKeyKey] = helper.Key
+		if helper.CA != "" {
+			entries[corev1.ServiceAccountRootCAKey] = helper.CA
+		}
+	}
+	if helper := spec.BasicAuth; helper != nil {
+		entries[corev1.BasicAuthUsernameKey] = helper.Username
+		entries[corev1.BasicAuthPasswordKey] = helper.Password
+	}
+	return entries, nil
+}
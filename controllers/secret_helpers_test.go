@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+)
+
+var _ = Describe("Typed secret helpers", func() {
+	It("Should expand a dockerConfig into a .dockerconfigjson", func() {
+		entries, err := expandSecretHelpers(&gitopssecretsnappcloudiov1alpha1.SopsSecretSpec{
+			DockerConfig: &gitopssecretsnappcloudiov1alpha1.DockerConfigHelper{
+				Registry: "registry.example.com:5000",
+				Username: "deployer",
+				Password: "s3cr3t",
+			},
+		})
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(1))
+
+		dockerConfig := dockerConfigJSON{}
+		Expect(json.Unmarshal([]byte(entries[".dockerconfigjson"]), &dockerConfig)).To(Succeed())
+		Expect(dockerConfig.Auths).To(HaveKeyWithValue("registry.example.com:5000", dockerConfigAuth{
+			Username: "deployer",
+			Password: "s3cr3t",
+			Auth:     "ZGVwbG95ZXI6czNjcjN0",
+		}))
+
+		pullSecret := &corev1.Secret{Data: map[string][]byte{".dockerconfigjson": []byte(entries[".dockerconfigjson"])}}
+		username, password, err := registryCredentials(pullSecret, "registry.example.com:5000")
+		Expect(err).To(BeNil())
+		Expect(username).To(Equal("deployer"))
+		Expect(password).To(Equal("s3cr3t"))
+	})
+
+	It("Should expand tls and basicAuth into their secret keys", func() {
+		entries, err := expandSecretHelpers(&gitopssecretsnappcloudiov1alpha1.SopsSecretSpec{
+			TLS: &gitopssecretsnappcloudiov1alpha1.TLSHelper{Certificate: "cert", Key: "key"},
+		})
+		Expect(err).To(BeNil())
+		Expect(entries).To(Equal(map[string]string{"tls.crt": "cert", "tls.key": "key"}))
+
+		entries, err = expandSecretHelpers(&gitopssecretsnappcloudiov1alpha1.SopsSecretSpec{
+			BasicAuth: &gitopssecretsnappcloudiov1alpha1.BasicAuthHelper{Username: "admin", Password: "s3cr3t"},
+		})
+		Expect(err).To(BeNil())
+		Expect(entries).To(Equal(map[string]string{"username": "admin", "password": "s3cr3t"}))
+	})
+})
@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"go.mozilla.org/sops/v3"
+	sopsdotenv "go.mozilla.org/sops/v3/stores/dotenv"
+	sopsini "go.mozilla.org/sops/v3/stores/ini"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+	"github.com/snapp-incubator/sops-operator/lang"
+)
+
+// iniDefaultSection holds the keys written before any section of an ini document
+const iniDefaultSection = "DEFAULT"
+
+// decryptSopsDocument decrypts a document carrying its own sops metadata. It
+// isn't mutated by the apiserver, so its mac is verified the way sops does.
+func decryptSopsDocument(format string, data []byte, verifyMAC bool, passphrases ...string) ([]byte, error) {
+	cleartext, mac, treeMAC, err := customDecryptData(data, format, sopsMACOnlyEncrypted(format, data), passphrases...)
+	if err != nil {
+		return nil, err
+	}
+	if verifyMAC && mac != treeMAC {
+		return nil, errSopsSecretMACMismatch
+	}
+	return cleartext, nil
+}
+
+// decryptSopsDocumentWithGPGKeys decrypts a document with the GPGKeys, see
+// decryptWithGPGKeys. It returns the cleartext and the comma separated keys
+// which decrypted it, or the status message and error of the failure.
+func decryptSopsDocumentWithGPGKeys(
+	ctx context.Context,
+	c client.Client,
+	logger logr.Logger,
+	namespace string,
+	gpgKeys []*gitopssecretsnappcloudiov1alpha1.GPGKey,
+	format string,
+	data []byte,
+	verifyMAC bool,
+) ([]byte, string, string, error) {
+	var cleartext []byte
+	_, gpgKeyRef, message, err := decryptWithGPGKeys(ctx, c, logger, namespace, gpgKeys,
+		sopsDocumentKeyGroups(format, data) > 1, lang.ErrSopsFileMACMismatch,
+		func(passphrases ...string) (err error) {
+			cleartext, err = decryptSopsDocument(format, data, verifyMAC, passphrases...)
+			return err
+		})
+	if err != nil {
+		return nil, "", message, err
+	}
+	return cleartext, gpgKeyRef, "", nil
+}
+
+// decryptWithGPGKeys calls decrypt with the passphrase of each GPGKey in
+// order, then with all of them together when splitKey tells the data key is
+// shamir split. It returns the keys which decrypted and their comma
+// separated references, or the status message and error of the failure,
+// using macMismatchMessage when the mac doesn't match.
+func decryptWithGPGKeys(
+	ctx context.Context,
+	c client.Client,
+	logger logr.Logger,
+	namespace string,
+	gpgKeys []*gitopssecretsnappcloudiov1alpha1.GPGKey,
+	splitKey bool,
+	macMismatchMessage string,
+	decrypt func(passphrases ...string) error,
+) ([]*gitopssecretsnappcloudiov1alpha1.GPGKey, string, string, error) {
+	decryptionAttempted, allKeysExpired := false, true
+	var passphrases, gpgKeyRefs []string
+	var decryptingGPGKeys []*gitopssecretsnappcloudiov1alpha1.GPGKey
+	var macErr, lastErr error
+	for _, gpgKey := range gpgKeys {
+		passphrase, err := getGPGKeyPassphrase(ctx, c, gpgKey)
+		if err != nil {
+			logger.Info("Error fetching GPGKey passphrase", "GPGKey", gpgKey.Name, "error", err)
+			if !decryptionAttempted {
+				lastErr = missingReferenceError(err)
+			}
+			continue
+		}
+		gpgKeyRef := gpgKey.Name
+		if gpgKey.Namespace != namespace {
+			gpgKeyRef = gpgKey.Namespace + "/" + gpgKey.Name
+		}
+		passphrases = append(passphrases, passphrase)
+		gpgKeyRefs = append(gpgKeyRefs, gpgKeyRef)
+		decryptingGPGKeys = append(decryptingGPGKeys, gpgKey)
+
+		err = decrypt(passphrase)
+		if err == nil {
+			return []*gitopssecretsnappcloudiov1alpha1.GPGKey{gpgKey}, gpgKeyRef, "", nil
+		}
+		decryptionAttempted = true
+		allKeysExpired = allKeysExpired && isGPGKeyExpired(gpgKey)
+		lastErr = cryptoError(err)
+		if isMACError(err) {
+			// every key decrypts the same data key, so the others would fail the same way
+			macErr = err
+			break
+		}
+	}
+
+	// the key groups of a shamir split data key are usually encrypted for
+	// different parties, so no single GPGKey can recover it alone
+	if decryptionAttempted && macErr == nil && splitKey && len(passphrases) > 1 {
+		err := decrypt(passphrases...)
+		if err == nil {
+			return decryptingGPGKeys, strings.Join(gpgKeyRefs, ","), "", nil
+		}
+		lastErr = cryptoError(err)
+		if isMACError(err) {
+			macErr = err
+		}
+	}
+
+	switch {
+	case !decryptionAttempted:
+		return nil, "", lang.ErrGPGKeyPassphraseFetchFail, lastErr
+	case macErr == errSopsSecretMACUnverifiable:
+		return nil, "", lang.ErrSopsSecretMACUnverifiable, lastErr
+	case macErr != nil:
+		return nil, "", macMismatchMessage, lastErr
+	case allKeysExpired:
+		return nil, "", lang.ErrGPGKeyExpired, lastErr
+	default:
+		return nil, "", lang.ErrSopsSecretDecryptionFailed, lastErr
+	}
+}
+
+// isMACError checks for a decryption which recovered the data key but whose
+// sops mac doesn't match or can't be verified
+func isMACError(err error) bool {
+	return err == errSopsSecretMACMismatch || err == errSopsSecretMACUnverifiable
+}
+
+// expandSopsDocument returns an entry per key of a decrypted dotenv or ini
+// document. Keys of ini sections other than the default one are prefixed
+// with the section name and a dot.
+func expandSopsDocument(document *gitopssecretsnappcloudiov1alpha1.SopsDocument) (map[string]string, error) {
+	if document == nil {
+		return nil, nil
+	}
+
+	var store sops.Store = &sopsdotenv.Store{}
+	if document.Format == "ini" {
+		store = &sopsini.Store{}
+	}
+	branches, err := store.LoadPlainFile([]byte(document.Data))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := map[string]string{}
+	for _, branch := range branches {
+		for _, item := range branch {
+			key, ok := item.Key.(string)
+			if !ok {
+				// comments
+				continue
+			}
+			section, ok := item.Value.(sops.TreeBranch)
+			if !ok {
+				entries[key] = fmt.Sprint(item.Value)
+				continue
+			}
+			for _, sectionItem := range section {
+				sectionKey, ok := sectionItem.Key.(string)
+				if !ok {
+					continue
+				}
+				if key != iniDefaultSection {
+					sectionKey = key + "." + sectionKey
+				}
+				entries[sectionKey] = fmt.Sprint(sectionItem.Value)
+			}
+		}
+	}
+
+	for key := range entries {
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return nil, fmt.Errorf("document entry %q is not a valid secret key: %v", key, errs)
+		}
+	}
+	return entries, nil
+}
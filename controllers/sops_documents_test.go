@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/snapp-incubator/sops-operator/lang"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+)
+
+var _ = Describe("SopsSecret documents", func() {
+	It("Should expand the entries of a dotenv document", func() {
+		entries, err := expandSopsDocument(&gitopssecretsnappcloudiov1alpha1.SopsDocument{
+			Format: "dotenv",
+			Data:   "# database\nUSER=admin\nPASS=secret\n",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(Equal(map[string]string{"USER": "admin", "PASS": "secret"}))
+	})
+
+	It("Should prefix the keys of ini sections", func() {
+		entries, err := expandSopsDocument(&gitopssecretsnappcloudiov1alpha1.SopsDocument{
+			Format: "ini",
+			Data:   "top=1\n[db]\nuser=admin\n",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(Equal(map[string]string{"top": "1", "db.user": "admin"}))
+	})
+
+	It("Should reject entries that aren't valid secret keys", func() {
+		_, err := expandSopsDocument(&gitopssecretsnappcloudiov1alpha1.SopsDocument{
+			Format: "ini",
+			Data:   "[my db]\nuser=admin\n",
+		})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should refuse document entries already set by stringData", func() {
+		stringData := map[string]string{"USER": "admin"}
+		err := mergeChildEntries(stringData, map[string]string{"USER": "root", "PASS": "secret"}, "document")
+		Expect(err).To(HaveOccurred())
+		Expect(stringData).To(Equal(map[string]string{"USER": "admin"}))
+
+		Expect(mergeChildEntries(stringData, map[string]string{"PASS": "secret"}, "document")).To(Succeed())
+		Expect(stringData).To(Equal(map[string]string{"USER": "admin", "PASS": "secret"}))
+	})
+
+	Context("Decrypting with GPGKeys", func() {
+		gpgKeys := []*gitopssecretsnappcloudiov1alpha1.GPGKey{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "default"},
+				Spec:       gitopssecretsnappcloudiov1alpha1.GPGKeySpec{Passphrase: "first"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "second", Namespace: "other"},
+				Spec:       gitopssecretsnappcloudiov1alpha1.GPGKeySpec{Passphrase: "second"},
+			},
+		}
+		// shamirDecrypt only decrypts with the passphrases of both key groups
+		shamirDecrypt := func(passphrases ...string) error {
+			if len(passphrases) == 2 {
+				return nil
+			}
+			return fmt.Errorf("not enough key groups")
+		}
+
+		It("Should combine the GPGKeys of a shamir split data key", func() {
+			decryptingGPGKeys, gpgKeyRef, _, err := decryptWithGPGKeys(context.Background(), nil, ctrl.Log, "default", gpgKeys,
+				true, lang.ErrSopsFileMACMismatch, shamirDecrypt)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(decryptingGPGKeys).To(Equal(gpgKeys))
+			Expect(gpgKeyRef).To(Equal("first,other/second"))
+		})
+
+		It("Should not combine the GPGKeys of a data key which isn't split", func() {
+			_, _, message, err := decryptWithGPGKeys(context.Background(), nil, ctrl.Log, "default", gpgKeys,
+				false, lang.ErrSopsFileMACMismatch, shamirDecrypt)
+			Expect(classifyError(err)).To(Equal(errorClassCrypto))
+			Expect(message).To(Equal(lang.ErrSopsSecretDecryptionFailed))
+		})
+
+		It("Should stop at a mac mismatch", func() {
+			attempts := 0
+			_, _, message, err := decryptWithGPGKeys(context.Background(), nil, ctrl.Log, "default", gpgKeys,
+				true, lang.ErrSopsSecretMACMismatch, func(passphrases ...string) error {
+					attempts++
+					return errSopsSecretMACMismatch
+				})
+			Expect(classifyError(err)).To(Equal(errorClassCrypto))
+			Expect(message).To(Equal(lang.ErrSopsSecretMACMismatch))
+			Expect(attempts).To(Equal(1))
+		})
+	})
+})
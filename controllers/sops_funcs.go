@@ -21,20 +21,23 @@ import (
 var statusSuccess = color.New(color.FgGreen).Sprint("SUCCESS")
 var statusFailed = color.New(color.FgRed).Sprint("FAILED")
 
-func GetDataKeyCustom(t sops.Metadata, passphrase string) ([]byte, error) {
+func GetDataKeyCustom(t sops.Metadata, passphrases ...string) ([]byte, error) {
 	return GetDataKeyWithKeyServicesCustom([]keyservice.KeyServiceClient{
 		keyservice.NewLocalClient(),
-	}, t, passphrase)
+	}, t, passphrases...)
 }
 
-func GetDataKeyWithKeyServicesCustom(svcs []keyservice.KeyServiceClient, m sops.Metadata, passphrase string) ([]byte, error) {
+// GetDataKeyWithKeyServicesCustom recovers the data key, trying every passphrase
+// on the pgp keys of each key group, as the groups of a shamir split data key
+// are usually encrypted for the keys of different parties
+func GetDataKeyWithKeyServicesCustom(svcs []keyservice.KeyServiceClient, m sops.Metadata, passphrases ...string) ([]byte, error) {
 	getDataKeyErr := getDataKeyError{
 		RequiredSuccessfulKeyGroups: m.ShamirThreshold,
 		GroupResults:                make([]error, len(m.KeyGroups)),
 	}
 	var parts [][]byte
 	for i, group := range m.KeyGroups {
-		part, err := decryptKeyGroupCustom(group, svcs, passphrase)
+		part, err := decryptKeyGroupCustom(group, svcs, passphrases)
 		if err == nil {
 			parts = append(parts, part)
 		}
@@ -59,10 +62,10 @@ func GetDataKeyWithKeyServicesCustom(svcs []keyservice.KeyServiceClient, m sops.
 	return dataKey, nil
 }
 
-func decryptKeyGroupCustom(group sops.KeyGroup, svcs []keyservice.KeyServiceClient, passphrase string) ([]byte, error) {
+func decryptKeyGroupCustom(group sops.KeyGroup, svcs []keyservice.KeyServiceClient, passphrases []string) ([]byte, error) {
 	var keyErrs []error
 	for _, key := range group {
-		part, err := decryptKeyCustom(key, svcs, passphrase)
+		part, err := decryptKeyCustom(key, svcs, passphrases)
 		if err != nil {
 			keyErrs = append(keyErrs, err)
 		} else {
@@ -72,18 +75,23 @@ func decryptKeyGroupCustom(group sops.KeyGroup, svcs []keyservice.KeyServiceClie
 	return nil, decryptKeyErrors(keyErrs)
 }
 
-func decryptKeyCustom(key keys.MasterKey, svcs []keyservice.KeyServiceClient, passphrase string) ([]byte, error) {
+func decryptKeyCustom(key keys.MasterKey, svcs []keyservice.KeyServiceClient, passphrases []string) ([]byte, error) {
 	svcKey := keyservice.KeyFromMasterKey(key)
-	var part []byte
 	decryptErr := decryptKeyError{
 		keyName: key.ToString(),
 	}
-	part, err := decryptWithPgp(svcKey.GetPgpKey().Fingerprint, key.EncryptedDataKey(), passphrase)
-	if err != nil {
-		return []byte{}, err
+	pgpKey := svcKey.GetPgpKey()
+	if pgpKey == nil {
+		// only pgp keys can be decrypted with the passphrase of a GPGKey
+		decryptErr.errs = append(decryptErr.errs, fmt.Errorf("master key is not a pgp key"))
+		return nil, &decryptErr
 	}
-	if part != nil {
-		return part, nil
+	for _, passphrase := range passphrases {
+		part, err := decryptWithPgp(pgpKey.Fingerprint, key.EncryptedDataKey(), passphrase)
+		if err == nil {
+			return part, nil
+		}
+		decryptErr.errs = append(decryptErr.errs, err)
 	}
 	return nil, &decryptErr
 }
@@ -0,0 +1,18 @@
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.mozilla.org/sops/v3"
+	sopsage "go.mozilla.org/sops/v3/age"
+)
+
+var _ = Describe("Sops data key", func() {
+	It("Should fail on a key group without pgp keys instead of panicking", func() {
+		metadata := sops.Metadata{
+			KeyGroups: []sops.KeyGroup{{&sopsage.MasterKey{Recipient: "age1example"}}},
+		}
+		_, err := GetDataKeyCustom(metadata, "passphrase")
+		Expect(err).To(HaveOccurred())
+	})
+})
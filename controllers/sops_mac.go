@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"hash"
+	"strings"
+
+	"go.mozilla.org/sops/v3"
+	"sigs.k8s.io/yaml"
+)
+
+// macOnlyEncryptedCipher hashes the values it decrypts, which are the
+// encrypted values of the tree in the order sops walks them. That is the mac
+// sops writes with mac_only_encrypted, which sops v3.7 doesn't know about.
+type macOnlyEncryptedCipher struct {
+	sops.Cipher
+	hash hash.Hash
+}
+
+// Decrypt decrypts the value and adds it to the mac, unless it is a comment
+func (c *macOnlyEncryptedCipher) Decrypt(ciphertext string, key []byte, additionalData string) (interface{}, error) {
+	value, err := c.Cipher.Decrypt(ciphertext, key, additionalData)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := value.(sops.Comment); ok {
+		return value, nil
+	}
+	valueBytes, err := sops.ToBytes(value)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = c.hash.Write(valueBytes)
+	return value, nil
+}
+
+// sopsMACOnlyEncrypted tells whether the metadata of a sops document sets
+// mac_only_encrypted, which is dropped by the stores of sops v3.7
+func sopsMACOnlyEncrypted(format string, data []byte) bool {
+	switch format {
+	case "dotenv":
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			if strings.TrimSpace(scanner.Text()) == "sops_mac_only_encrypted=true" {
+				return true
+			}
+		}
+		return false
+	case "ini":
+		section := ""
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+				section = strings.TrimSpace(line[1 : len(line)-1])
+				continue
+			}
+			parts := strings.SplitN(line, "=", 2)
+			if section == "sops" && len(parts) == 2 && strings.TrimSpace(parts[0]) == "mac_only_encrypted" {
+				return strings.TrimSpace(parts[1]) == "true"
+			}
+		}
+		return false
+	default:
+		// the json of json and binary documents is valid yaml
+		document := struct {
+			Sops struct {
+				MACOnlyEncrypted bool `json:"mac_only_encrypted"`
+			} `json:"sops"`
+		}{}
+		if err := yaml.Unmarshal(data, &document); err != nil {
+			return false
+		}
+		return document.Sops.MACOnlyEncrypted
+	}
+}
+
+// sortedJSON sorts the keys of every object of a json document, the way the
+// apiserver stores custom resources
+func sortedJSON(data []byte) ([]byte, error) {
+	var document interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	return json.Marshal(document)
+}
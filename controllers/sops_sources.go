@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+)
+
+// fetchSopsSource returns the encrypted file of spec.sourceRef and its
+// revision. A missing object, key or artifact is a missing reference.
+func (r *SopsSecretReconciler) fetchSopsSource(
+	ctx context.Context,
+	encryptedSopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret,
+) ([]byte, string, error) {
+	sourceRef := encryptedSopsSecret.Spec.SourceRef
+	if sourceRef == nil {
+		return nil, "", nil
+	}
+	namespace := encryptedSopsSecret.Namespace
+
+	switch {
+	case sourceRef.ConfigMap != nil:
+		configMap := &corev1.ConfigMap{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: sourceRef.ConfigMap.Name}, configMap); err != nil {
+			return nil, "", missingReferenceError(err)
+		}
+		if data, ok := configMap.Data[sourceRef.ConfigMap.Key]; ok {
+			return []byte(data), configMap.ResourceVersion, nil
+		}
+		if data, ok := configMap.BinaryData[sourceRef.ConfigMap.Key]; ok {
+			return data, configMap.ResourceVersion, nil
+		}
+		return nil, "", missingReferenceError(fmt.Errorf("configmap %s has no key %s", sourceRef.ConfigMap.Name, sourceRef.ConfigMap.Key))
+
+	case sourceRef.Secret != nil:
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: sourceRef.Secret.Name}, secret); err != nil {
+			return nil, "", missingReferenceError(err)
+		}
+		if data, ok := secret.Data[sourceRef.Secret.Key]; ok {
+			return data, secret.ResourceVersion, nil
+		}
+		return nil, "", missingReferenceError(fmt.Errorf("secret %s has no key %s", sourceRef.Secret.Name, sourceRef.Secret.Key))
+
+	case sourceRef.OCI != nil:
+		registryClient := &ociClient{httpClient: ociHTTPClient}
+		if pullSecretName := sourceRef.OCI.PullSecretName; pullSecretName != "" {
+			pullSecret := &corev1.Secret{}
+			if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: pullSecretName}, pullSecret); err != nil {
+				return nil, "", missingReferenceError(err)
+			}
+			registry, _, err := splitOCIRepository(sourceRef.OCI.Repository)
+			if err != nil {
+				return nil, "", cryptoError(err)
+			}
+			if registryClient.username, registryClient.password, err = registryCredentials(pullSecret, registry); err != nil {
+				return nil, "", missingReferenceError(err)
+			}
+		}
+		data, digest, err := registryClient.pull(ctx, sourceRef.OCI)
+		if _, notFound := err.(*errOCINotFound); notFound {
+			return nil, "", missingReferenceError(err)
+		}
+		return data, digest, err
+	}
+	return nil, "", cryptoError(fmt.Errorf("sourceRef sets no source"))
+}
+
+// expandSopsSource returns the child secret entries of a decrypted sourceRef,
+// the whole file under its key or an entry per top-level key of the file
+func expandSopsSource(sourceRef *gitopssecretsnappcloudiov1alpha1.SopsSourceReference, cleartext []byte) (map[string]string, error) {
+	if sourceRef == nil {
+		return nil, nil
+	}
+	if sourceRef.Key != "" {
+		return map[string]string{sourceRef.Key: string(cleartext)}, nil
+	}
+	return explodeSopsFile(sourceRef.Format, cleartext)
+}
+
+// sopsSecretsReferencingSource enqueues the SopsSecrets of the namespace of
+// obj whose sourceRef or OCI pull secret is obj
+func (r *SopsSecretReconciler) sopsSecretsReferencingSource(ctx context.Context, obj client.Object) []reconcile.Request {
+	sopsSecrets := &gitopssecretsnappcloudiov1alpha1.SopsSecretList{}
+	if err := r.List(ctx, sopsSecrets, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Info("Couldn't list sopssecrets", "source", client.ObjectKeyFromObject(obj), "error", err)
+		return nil
+	}
+	_, isConfigMap := obj.(*corev1.ConfigMap)
+	var requests []reconcile.Request
+	for _, sopsSecret := range sopsSecrets.Items {
+		sourceRef := sopsSecret.Spec.SourceRef
+		if sourceRef == nil {
+			continue
+		}
+		var name string
+		switch {
+		case isConfigMap && sourceRef.ConfigMap != nil:
+			name = sourceRef.ConfigMap.Name
+		case !isConfigMap && sourceRef.Secret != nil:
+			name = sourceRef.Secret.Name
+		case !isConfigMap && sourceRef.OCI != nil:
+			name = sourceRef.OCI.PullSecretName
+		}
+		if name == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: sopsSecret.Namespace, Name: sopsSecret.Name},
+			})
+		}
+	}
+	return requests
+}
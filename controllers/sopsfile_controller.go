@@ -0,0 +1,356 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/yaml"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+	"github.com/snapp-incubator/sops-operator/lang"
+)
+
+// SopsFileReconciler reconciles a SopsFile object
+type SopsFileReconciler struct {
+	client.Client
+	Scheme       *runtime.Scheme
+	Log          logr.Logger
+	RequeueAfter int64
+}
+
+//+kubebuilder:rbac:groups=gitopssecret.snappcloud.io,resources=sopsfiles,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gitopssecret.snappcloud.io,resources=sopsfiles/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=gitopssecret.snappcloud.io,resources=sopsfiles/finalizers,verbs=update
+
+// Reconcile decrypts the sops file of a SopsFile and writes it to its target
+// secret, either whole under one key or exploded into a key per top-level key.
+func (r *SopsFileReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.Log.Info("Reconciling", "sopsfile", req.NamespacedName)
+
+	sopsFile := &gitopssecretsnappcloudiov1alpha1.SopsFile{}
+	if err := r.Get(ctx, req.NamespacedName, sopsFile); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if finished, err := r.applyDeletionPolicy(ctx, req, sopsFile); finished {
+		if err != nil {
+			return resultForError(r.Log, r.RequeueAfter, "sopsfile", req, err)
+		}
+		return ctrl.Result{}, nil
+	}
+	if sopsFile.Spec.Suspend {
+		r.Log.Info("Reconciliation is suspended for this object", "sopsfile", req.NamespacedName)
+		sopsFile.Status.Health = lang.SopsHealthyStatus
+		sopsFile.Status.Message = lang.SopsSecretSuspended
+		_ = r.Status().Update(ctx, sopsFile)
+		return ctrl.Result{}, nil
+	}
+
+	gpgKeys, message, err := fetchGPGKeyRefs(ctx, r.Client, r.Log, sopsFile, sopsFile.Spec.GPGKeyRefs)
+	if err != nil {
+		return r.failed(ctx, req, sopsFile, message, err)
+	}
+
+	cleartext, err := r.decryptSopsFile(ctx, sopsFile, gpgKeys)
+	if err != nil {
+		return r.failed(ctx, req, sopsFile, sopsFile.Status.Message, err)
+	}
+
+	data := map[string][]byte{sopsFile.TargetKey(): cleartext}
+	if sopsFile.Spec.Target.Explode {
+		entries, err := explodeSopsFile(sopsFile.Spec.Format, cleartext)
+		if err != nil {
+			r.Log.Info("Exploding file error", "sopsfile", req.NamespacedName, "error", err)
+			return r.failed(ctx, req, sopsFile, lang.ErrSopsFileExplodeFailed, cryptoError(err))
+		}
+		data = make(map[string][]byte, len(entries))
+		for key, value := range entries {
+			data[key] = []byte(value)
+		}
+	}
+
+	dataHash, message, err := r.writeTargetSecret(ctx, sopsFile, data)
+	if err != nil {
+		r.Log.Info("Writing target secret error", "sopsfile", req.NamespacedName, "error", err)
+		return r.failed(ctx, req, sopsFile, message, err)
+	}
+
+	// target.name changed, the previous target secret goes
+	if previous := sopsFile.Status.TargetName; previous != "" && previous != sopsFile.TargetSecretName() {
+		orphan := sopsFile.Spec.DeletionPolicy == gitopssecretsnappcloudiov1alpha1.DeletionPolicyOrphan
+		if err := r.releaseTargetSecret(ctx, sopsFile, previous, orphan); err != nil {
+			r.Log.Info("Removing the previous target secret error", "sopsfile", req.NamespacedName, "error", err)
+			return r.failed(ctx, req, sopsFile, lang.ErrSopsFilePruneFailed, err)
+		}
+	}
+
+	sopsFile.Status.Health = lang.SopsHealthyStatus
+	sopsFile.Status.Message = ""
+	sopsFile.Status.DataHash = dataHash
+	sopsFile.Status.TargetName = sopsFile.TargetSecretName()
+	_ = r.Status().Update(ctx, sopsFile)
+
+	r.Log.Info("SopsFile is Healthy", "sopsfile", req.NamespacedName)
+	return ctrl.Result{}, nil
+}
+
+// failed records message in the status of the SopsFile and returns the
+// result matching the class of err
+func (r *SopsFileReconciler) failed(
+	ctx context.Context,
+	req ctrl.Request,
+	sopsFile *gitopssecretsnappcloudiov1alpha1.SopsFile,
+	message string,
+	err error,
+) (ctrl.Result, error) {
+	return reportFailure(ctx, r.Client, r.Log, r.RequeueAfter, "sopsfile", req, sopsFile, message, err)
+}
+
+// applyDeletionPolicy keeps the finalizer in line with spec.deletionPolicy and,
+// once the SopsFile is being deleted, orphans its target secret if asked to
+func (r *SopsFileReconciler) applyDeletionPolicy(
+	ctx context.Context,
+	req ctrl.Request,
+	sopsFile *gitopssecretsnappcloudiov1alpha1.SopsFile,
+) (bool, error) {
+	orphan := sopsFile.Spec.DeletionPolicy == gitopssecretsnappcloudiov1alpha1.DeletionPolicyOrphan
+
+	if sopsFile.DeletionTimestamp.IsZero() {
+		var finalizerChanged bool
+		if orphan {
+			finalizerChanged = controllerutil.AddFinalizer(sopsFile, gitopssecretsnappcloudiov1alpha1.SopsFileFinalizer)
+		} else {
+			finalizerChanged = controllerutil.RemoveFinalizer(sopsFile, gitopssecretsnappcloudiov1alpha1.SopsFileFinalizer)
+		}
+		if finalizerChanged {
+			if err := r.Update(ctx, sopsFile); err != nil {
+				return true, err
+			}
+		}
+		return false, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(sopsFile, gitopssecretsnappcloudiov1alpha1.SopsFileFinalizer) {
+		return true, nil
+	}
+	if orphan {
+		if err := r.releaseTargetSecret(ctx, sopsFile, sopsFile.Status.TargetName, true); err != nil {
+			sopsFile.SetHealth(lang.SopsUnHealthyStatus, lang.ErrSopsFileCouldNotOrphan)
+			_ = r.Status().Update(ctx, sopsFile)
+
+			r.Log.Info("Target secret orphaning error", "sopsfile", req.NamespacedName, "error", err)
+			return true, err
+		}
+	}
+	controllerutil.RemoveFinalizer(sopsFile, gitopssecretsnappcloudiov1alpha1.SopsFileFinalizer)
+	return true, r.Update(ctx, sopsFile)
+}
+
+// releaseTargetSecret deletes, or orphans when asked to, the secret named name
+// once it is no longer the target of the SopsFile. Secrets the SopsFile
+// doesn't control are left alone.
+func (r *SopsFileReconciler) releaseTargetSecret(
+	ctx context.Context,
+	sopsFile *gitopssecretsnappcloudiov1alpha1.SopsFile,
+	name string,
+	orphan bool,
+) error {
+	if name == "" {
+		return nil
+	}
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: sopsFile.Namespace, Name: name}, secret)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(secret, sopsFile) {
+		return nil
+	}
+	if orphan {
+		return orphanObject(ctx, r.Client, r.Log, sopsFile, secret)
+	}
+
+	r.Log.Info(
+		"Deleting the previous target secret",
+		"secret", name,
+		"namespace", sopsFile.Namespace,
+	)
+	return client.IgnoreNotFound(r.Delete(ctx, secret, client.Preconditions{UID: &secret.UID}))
+}
+
+// decryptSopsFile decrypts the file with the referenced GPGKeys and records
+// the keys which decrypted it in the status
+func (r *SopsFileReconciler) decryptSopsFile(
+	ctx context.Context,
+	sopsFile *gitopssecretsnappcloudiov1alpha1.SopsFile,
+	gpgKeys []*gitopssecretsnappcloudiov1alpha1.GPGKey,
+) ([]byte, error) {
+	verifyMAC := sopsFile.GetAnnotations()[gitopssecretsnappcloudiov1alpha1.SopsSecretSkipMACVerificationAnnotation] != "true"
+	cleartext, gpgKeyRef, message, err := decryptSopsDocumentWithGPGKeys(ctx, r.Client, r.Log, sopsFile.Namespace, gpgKeys,
+		sopsFile.Spec.Format, []byte(sopsFile.Spec.Data), verifyMAC)
+	if err != nil {
+		r.Log.Info("Decryption error", "sopsfile", client.ObjectKeyFromObject(sopsFile), "error", err)
+		sopsFile.Status.Message = message
+		return nil, err
+	}
+	sopsFile.Status.GPGKeyRef = gpgKeyRef
+	return cleartext, nil
+}
+
+// writeTargetSecret server-side applies the data of the target secret, which
+// has to be controlled by the SopsFile or allowed to be adopted by its
+// adoptionPolicy once it exists. An adopted secret is backed up first. It
+// returns the hash of the data, or the status message of a failure.
+func (r *SopsFileReconciler) writeTargetSecret(
+	ctx context.Context,
+	sopsFile *gitopssecretsnappcloudiov1alpha1.SopsFile,
+	data map[string][]byte,
+) (string, string, error) {
+	dataHash := secretDataChecksum(data)
+	secretType := corev1.SecretType(sopsFile.Spec.Target.Type)
+	if secretType == "" {
+		secretType = corev1.SecretTypeOpaque
+	}
+	appliedSecret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: sopsFile.Namespace,
+			Name:      sopsFile.TargetSecretName(),
+			Annotations: map[string]string{
+				gitopssecretsnappcloudiov1alpha1.SopsSecretDataHashAnnotation: dataHash,
+				gitopssecretsnappcloudiov1alpha1.SopsSecretOwnerUIDAnnotation: string(sopsFile.UID),
+			},
+		},
+		Type: secretType,
+		Data: data,
+	}
+	if err := controllerutil.SetControllerReference(sopsFile, appliedSecret, r.Scheme); err != nil {
+		return "", lang.ErrSopsSecretCouldNotUpdateChild, err
+	}
+
+	liveSecret := &corev1.Secret{}
+	err := r.Get(ctx, client.ObjectKeyFromObject(appliedSecret), liveSecret)
+	if errors.IsNotFound(err) {
+		if err := r.Patch(ctx, appliedSecret, client.Apply, client.FieldOwner(sopsSecretFieldManager), client.ForceOwnership); err != nil {
+			return "", lang.ErrSopsSecretCouldNotUpdateChild, err
+		}
+		return dataHash, "", nil
+	}
+	if err != nil {
+		return "", lang.ErrSopsSecretCouldNotUpdateChild, err
+	}
+
+	adopting := !metav1.IsControlledBy(liveSecret, sopsFile)
+	if adopting {
+		if !mayManage(sopsFile, sopsFile.Spec.AdoptionPolicy, liveSecret) {
+			// waits for the secret to be annotated or removed, which isn't watched
+			return "", lang.ErrSopsFileTargetNotOwned,
+				missingReferenceError(fmt.Errorf("secret %s already exists and is not owned by the sopsfile", liveSecret.Name))
+		}
+		if err := backupAdoptedObject(ctx, r.Client, r.Log, liveSecret); err != nil {
+			return "", lang.ErrSopsFileTargetBackupFailed, err
+		}
+	}
+	if _, _, err := applyChildSecret(ctx, r.Client, r.Log, liveSecret, appliedSecret, true,
+		adopting || isAnnotatedToBeManaged(liveSecret)); err != nil {
+		return "", lang.ErrSopsSecretCouldNotUpdateChild, err
+	}
+	return dataHash, "", nil
+}
+
+// explodeSopsFile returns an entry per top-level key of a decrypted file.
+// Scalars are written in their string form, nested maps and lists in the
+// format of the file.
+func explodeSopsFile(format string, cleartext []byte) (map[string]string, error) {
+	switch format {
+	case "binary":
+		return nil, fmt.Errorf("binary files have no keys to explode")
+	case "dotenv", "ini":
+		return expandSopsDocument(&gitopssecretsnappcloudiov1alpha1.SopsDocument{Format: format, Data: string(cleartext)})
+	}
+
+	jsonData := cleartext
+	if format == "yaml" {
+		var err error
+		if jsonData, err = yaml.YAMLToJSON(cleartext); err != nil {
+			return nil, err
+		}
+	}
+	var tree map[string]json.RawMessage
+	if err := json.Unmarshal(jsonData, &tree); err != nil {
+		return nil, fmt.Errorf("only files holding a map can be exploded: %w", err)
+	}
+
+	entries := make(map[string]string, len(tree))
+	for key, raw := range tree {
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return nil, fmt.Errorf("file key %q is not a valid secret key: %v", key, errs)
+		}
+		value, err := gitopssecretsnappcloudiov1alpha1.StringDataValue(apiextensionsv1.JSON{Raw: raw})
+		if err == nil {
+			entries[key] = value
+			continue
+		}
+		nested, err := renderNestedValue(format, raw)
+		if err != nil {
+			return nil, fmt.Errorf("file key %s: %w", key, err)
+		}
+		entries[key] = nested
+	}
+	return entries, nil
+}
+
+func renderNestedValue(format string, raw json.RawMessage) (string, error) {
+	if format == "json" {
+		compacted := bytes.Buffer{}
+		if err := json.Compact(&compacted, raw); err != nil {
+			return "", err
+		}
+		return compacted.String(), nil
+	}
+	rendered, err := yaml.JSONToYAML(raw)
+	return string(rendered), err
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *SopsFileReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&gitopssecretsnappcloudiov1alpha1.SopsFile{}, builder.WithPredicates(specChangedPredicate)).
+		Owns(&corev1.Secret{}).
+		Watches(&gitopssecretsnappcloudiov1alpha1.GPGKey{}, gpgKeyReferrersHandler(r.Client, r.Log, func() client.ObjectList {
+			return &gitopssecretsnappcloudiov1alpha1.SopsFileList{}
+		})).
+		Complete(r)
+}
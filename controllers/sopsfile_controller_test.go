@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+	"github.com/snapp-incubator/sops-operator/lang"
+)
+
+var _ = Describe("SopsFile explode", func() {
+	It("Should write scalars in their string form and nested values as yaml", func() {
+		entries, err := explodeSopsFile("yaml", []byte("user: admin\nport: 5432\ndatabase:\n  password: secret\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(Equal(map[string]string{
+			"user":     "admin",
+			"port":     "5432",
+			"database": "password: secret\n",
+		}))
+	})
+
+	It("Should keep nested values of json files as json", func() {
+		entries, err := explodeSopsFile("json", []byte(`{"tls": true, "hosts": ["a", "b"]}`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(Equal(map[string]string{"tls": "true", "hosts": `["a","b"]`}))
+	})
+
+	It("Should explode the entries of dotenv files", func() {
+		entries, err := explodeSopsFile("dotenv", []byte("USER=admin\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(Equal(map[string]string{"USER": "admin"}))
+	})
+
+	It("Should refuse to explode binary files", func() {
+		_, err := explodeSopsFile("binary", []byte("certificate"))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("SopsFile target", func() {
+	testScheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(testScheme)
+	_ = gitopssecretsnappcloudiov1alpha1.AddToScheme(testScheme)
+
+	newSopsFile := func(adoptionPolicy string) *gitopssecretsnappcloudiov1alpha1.SopsFile {
+		return &gitopssecretsnappcloudiov1alpha1.SopsFile{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team", UID: "b2c4e6f8-0000-0000-0000-000000000000"},
+			Spec:       gitopssecretsnappcloudiov1alpha1.SopsFileSpec{AdoptionPolicy: adoptionPolicy},
+		}
+	}
+	newSecret := func(annotations map[string]string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team", UID: "a1b2c3d4-0000-0000-0000-000000000000", Annotations: annotations},
+			Type:       corev1.SecretTypeOpaque,
+			Data:       map[string][]byte{"app": []byte("previous")},
+		}
+	}
+	// the fake client can't apply, so patches are only recorded
+	newReconciler := func(calls *[]string, objects ...client.Object) *SopsFileReconciler {
+		recordingClient := interceptor.NewClient(fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).Build(), interceptor.Funcs{
+			Patch: func(_ context.Context, _ client.WithWatch, _ client.Object, patch client.Patch, _ ...client.PatchOption) error {
+				*calls = append(*calls, string(patch.Type()))
+				return nil
+			},
+		})
+		return &SopsFileReconciler{Client: recordingClient, Scheme: testScheme, Log: ctrl.Log}
+	}
+
+	It("Should back up an annotated target secret before adopting it", func() {
+		live := newSecret(map[string]string{gitopssecretsnappcloudiov1alpha1.SopsSecretManagedAnnotation: "true"})
+		var calls []string
+		reconciler := newReconciler(&calls, live)
+
+		dataHash, message, err := reconciler.writeTargetSecret(context.Background(), newSopsFile(""), map[string][]byte{"app": []byte("decrypted")})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(message).To(BeEmpty())
+		Expect(dataHash).NotTo(BeEmpty())
+		Expect(calls).To(ContainElement(string(types.ApplyPatchType)))
+
+		backup := &corev1.Secret{}
+		Expect(reconciler.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: "app-backup-a1b2c3d4"}, backup)).To(Succeed())
+		Expect(backup.Data).To(Equal(map[string][]byte{"app": []byte("previous")}))
+	})
+
+	It("Should wait for a target secret its adoption policy excludes", func() {
+		live := newSecret(map[string]string{gitopssecretsnappcloudiov1alpha1.SopsSecretManagedAnnotation: "true"})
+		var calls []string
+
+		_, message, err := newReconciler(&calls, live).writeTargetSecret(context.Background(),
+			newSopsFile(gitopssecretsnappcloudiov1alpha1.AdoptionPolicyNever), map[string][]byte{"app": []byte("decrypted")})
+		Expect(classifyError(err)).To(Equal(errorClassMissingReference))
+		Expect(message).To(Equal(lang.ErrSopsFileTargetNotOwned))
+		Expect(calls).To(BeEmpty())
+	})
+
+	It("Should take back a target secret which lost its owner reference", func() {
+		sopsFile := newSopsFile(gitopssecretsnappcloudiov1alpha1.AdoptionPolicyNever)
+		live := newSecret(map[string]string{gitopssecretsnappcloudiov1alpha1.SopsSecretOwnerUIDAnnotation: string(sopsFile.UID)})
+		var calls []string
+
+		_, message, err := newReconciler(&calls, live).writeTargetSecret(context.Background(), sopsFile, map[string][]byte{"app": []byte("decrypted")})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(message).To(BeEmpty())
+	})
+	It("Should delete or orphan the previous target secret of a renamed target", func() {
+		sopsFile := newSopsFile("")
+		previous := func() *corev1.Secret {
+			secret := newSecret(map[string]string{gitopssecretsnappcloudiov1alpha1.SopsSecretOwnerUIDAnnotation: string(sopsFile.UID)})
+			secret.Name = "old-app"
+			Expect(controllerutil.SetControllerReference(sopsFile, secret, testScheme)).To(Succeed())
+			return secret
+		}
+
+		var calls []string
+		reconciler := newReconciler(&calls, previous())
+		Expect(reconciler.releaseTargetSecret(context.Background(), sopsFile, "old-app", true)).To(Succeed())
+		orphaned := &corev1.Secret{}
+		Expect(reconciler.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: "old-app"}, orphaned)).To(Succeed())
+		Expect(orphaned.OwnerReferences).To(BeEmpty())
+		Expect(orphaned.Annotations).NotTo(HaveKey(gitopssecretsnappcloudiov1alpha1.SopsSecretOwnerUIDAnnotation))
+
+		reconciler = newReconciler(&calls, previous())
+		Expect(reconciler.releaseTargetSecret(context.Background(), sopsFile, "old-app", false)).To(Succeed())
+		err := reconciler.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: "old-app"}, &corev1.Secret{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("Should leave a previous target secret it doesn't control", func() {
+		live := newSecret(nil)
+		var calls []string
+		reconciler := newReconciler(&calls, live)
+
+		Expect(reconciler.releaseTargetSecret(context.Background(), newSopsFile(""), "app", false)).To(Succeed())
+		Expect(reconciler.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: "app"}, &corev1.Secret{})).To(Succeed())
+	})
+})
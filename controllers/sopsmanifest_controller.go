@@ -0,0 +1,410 @@
+/*
+Copyright 2022.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/yaml"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+	"github.com/snapp-incubator/sops-operator/lang"
+)
+
+// SopsManifestReconciler reconciles a SopsManifest object
+type SopsManifestReconciler struct {
+	client.Client
+	Scheme       *runtime.Scheme
+	Log          logr.Logger
+	Recorder     record.EventRecorder
+	RequeueAfter int64
+	// AllowedKinds are the only kinds SopsManifests may apply, they are
+	// watched to correct the drift of the applied objects
+	AllowedKinds []schema.GroupVersionKind
+}
+
+//+kubebuilder:rbac:groups=gitopssecret.snappcloud.io,resources=sopsmanifests,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gitopssecret.snappcloud.io,resources=sopsmanifests/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=gitopssecret.snappcloud.io,resources=sopsmanifests/finalizers,verbs=update
+
+// Reconcile decrypts the manifest of a SopsManifest and server-side applies
+// its object, owned by the SopsManifest, in the namespace of the SopsManifest.
+func (r *SopsManifestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	r.Log.Info("Reconciling", "sopsmanifest", req.NamespacedName)
+
+	sopsManifest := &gitopssecretsnappcloudiov1alpha1.SopsManifest{}
+	if err := r.Get(ctx, req.NamespacedName, sopsManifest); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if finished, err := r.applyDeletionPolicy(ctx, req, sopsManifest); finished {
+		if err != nil {
+			return resultForError(r.Log, r.RequeueAfter, "sopsmanifest", req, err)
+		}
+		return ctrl.Result{}, nil
+	}
+	if sopsManifest.Spec.Suspend {
+		r.Log.Info("Reconciliation is suspended for this object", "sopsmanifest", req.NamespacedName)
+		sopsManifest.Status.Health = lang.SopsHealthyStatus
+		sopsManifest.Status.Message = lang.SopsSecretSuspended
+		_ = r.Status().Update(ctx, sopsManifest)
+		return ctrl.Result{}, nil
+	}
+
+	gpgKeys, message, err := fetchGPGKeyRefs(ctx, r.Client, r.Log, sopsManifest, sopsManifest.Spec.GPGKeyRefs)
+	if err != nil {
+		return r.failed(ctx, req, sopsManifest, message, err)
+	}
+
+	verifyMAC := sopsManifest.GetAnnotations()[gitopssecretsnappcloudiov1alpha1.SopsSecretSkipMACVerificationAnnotation] != "true"
+	cleartext, gpgKeyRef, message, err := decryptSopsDocumentWithGPGKeys(ctx, r.Client, r.Log, sopsManifest.Namespace, gpgKeys,
+		sopsManifest.Spec.Format, []byte(sopsManifest.Spec.Data), verifyMAC)
+	if err != nil {
+		r.Log.Info("Decryption error", "sopsmanifest", req.NamespacedName, "error", err)
+		return r.failed(ctx, req, sopsManifest, message, err)
+	}
+	sopsManifest.Status.GPGKeyRef = gpgKeyRef
+
+	object, err := parseSopsManifest(cleartext, sopsManifest.Namespace)
+	if err != nil {
+		r.Log.Info("Invalid manifest", "sopsmanifest", req.NamespacedName, "error", err)
+		return r.failed(ctx, req, sopsManifest, lang.ErrSopsManifestInvalid, cryptoError(err))
+	}
+	if err := r.checkKindAllowed(object.GroupVersionKind()); err != nil {
+		r.Log.Info("Kind is not allowed", "sopsmanifest", req.NamespacedName, "error", err)
+		return r.failed(ctx, req, sopsManifest, lang.ErrSopsManifestKindNotAllowed, err)
+	}
+
+	dataHash := fmt.Sprintf("%x", sha256.Sum256(cleartext))
+	annotations := object.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[gitopssecretsnappcloudiov1alpha1.SopsSecretDataHashAnnotation] = dataHash
+	annotations[gitopssecretsnappcloudiov1alpha1.SopsSecretOwnerUIDAnnotation] = string(sopsManifest.UID)
+	object.SetAnnotations(annotations)
+	if err := controllerutil.SetControllerReference(sopsManifest, object, r.Scheme); err != nil {
+		return r.failed(ctx, req, sopsManifest, lang.ErrSopsManifestApplyFailed, err)
+	}
+
+	if message, err := r.applyObject(ctx, sopsManifest, object, dataHash); err != nil {
+		r.Log.Info("Applying the manifest error", "sopsmanifest", req.NamespacedName, "error", err)
+		return r.failed(ctx, req, sopsManifest, message, err)
+	}
+
+	// the manifest was renamed or changed kind, its previous object goes
+	if previous := sopsManifest.Status.Object; previous != nil && !isManifestObject(previous, object) {
+		orphan := sopsManifest.Spec.DeletionPolicy == gitopssecretsnappcloudiov1alpha1.DeletionPolicyOrphan
+		if err := r.releaseObject(ctx, sopsManifest, previous, orphan); err != nil {
+			r.Log.Info("Removing the previously applied object error", "sopsmanifest", req.NamespacedName, "error", err)
+			return r.failed(ctx, req, sopsManifest, lang.ErrSopsManifestPruneFailed, err)
+		}
+	}
+
+	sopsManifest.Status.Health = lang.SopsHealthyStatus
+	sopsManifest.Status.Message = ""
+	sopsManifest.Status.DataHash = dataHash
+	sopsManifest.Status.Object = &gitopssecretsnappcloudiov1alpha1.ManifestObjectReference{
+		APIVersion: object.GetAPIVersion(),
+		Kind:       object.GetKind(),
+		Name:       object.GetName(),
+	}
+	_ = r.Status().Update(ctx, sopsManifest)
+
+	r.Log.Info("SopsManifest is Healthy", "sopsmanifest", req.NamespacedName)
+	return ctrl.Result{}, nil
+}
+
+// failed records message in the status of the SopsManifest and returns the
+// result matching the class of err
+func (r *SopsManifestReconciler) failed(
+	ctx context.Context,
+	req ctrl.Request,
+	sopsManifest *gitopssecretsnappcloudiov1alpha1.SopsManifest,
+	message string,
+	err error,
+) (ctrl.Result, error) {
+	return reportFailure(ctx, r.Client, r.Log, r.RequeueAfter, "sopsmanifest", req, sopsManifest, message, err)
+}
+
+// applyDeletionPolicy keeps the finalizer in line with spec.deletionPolicy and,
+// once the SopsManifest is being deleted, orphans its object if asked to
+func (r *SopsManifestReconciler) applyDeletionPolicy(
+	ctx context.Context,
+	req ctrl.Request,
+	sopsManifest *gitopssecretsnappcloudiov1alpha1.SopsManifest,
+) (bool, error) {
+	orphan := sopsManifest.Spec.DeletionPolicy == gitopssecretsnappcloudiov1alpha1.DeletionPolicyOrphan
+
+	if sopsManifest.DeletionTimestamp.IsZero() {
+		var finalizerChanged bool
+		if orphan {
+			finalizerChanged = controllerutil.AddFinalizer(sopsManifest, gitopssecretsnappcloudiov1alpha1.SopsManifestFinalizer)
+		} else {
+			finalizerChanged = controllerutil.RemoveFinalizer(sopsManifest, gitopssecretsnappcloudiov1alpha1.SopsManifestFinalizer)
+		}
+		if finalizerChanged {
+			if err := r.Update(ctx, sopsManifest); err != nil {
+				return true, err
+			}
+		}
+		return false, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(sopsManifest, gitopssecretsnappcloudiov1alpha1.SopsManifestFinalizer) {
+		return true, nil
+	}
+	if orphan {
+		if err := r.releaseObject(ctx, sopsManifest, sopsManifest.Status.Object, true); err != nil {
+			sopsManifest.SetHealth(lang.SopsUnHealthyStatus, lang.ErrSopsManifestCouldNotOrphan)
+			_ = r.Status().Update(ctx, sopsManifest)
+
+			r.Log.Info("Applied object orphaning error", "sopsmanifest", req.NamespacedName, "error", err)
+			return true, err
+		}
+	}
+	controllerutil.RemoveFinalizer(sopsManifest, gitopssecretsnappcloudiov1alpha1.SopsManifestFinalizer)
+	return true, r.Update(ctx, sopsManifest)
+}
+
+// applyObject server-side applies the object, which has to be controlled by
+// the SopsManifest or allowed to be adopted by its adoptionPolicy once it
+// exists. An adopted object is backed up first. Fields dropped from the
+// manifest are removed by the apply itself, as this operator owns them. A
+// changed object whose manifest is unchanged has drifted. It returns the
+// status message of a failure.
+func (r *SopsManifestReconciler) applyObject(
+	ctx context.Context,
+	sopsManifest *gitopssecretsnappcloudiov1alpha1.SopsManifest,
+	object *unstructured.Unstructured,
+	dataHash string,
+) (string, error) {
+	liveObject := &unstructured.Unstructured{}
+	liveObject.SetGroupVersionKind(object.GroupVersionKind())
+	err := r.Get(ctx, client.ObjectKeyFromObject(object), liveObject)
+	exists := err == nil
+	if err != nil && !errors.IsNotFound(err) {
+		return lang.ErrSopsManifestApplyFailed, err
+	}
+
+	adopting := exists && !metav1.IsControlledBy(liveObject, sopsManifest)
+	if adopting {
+		if !mayManage(sopsManifest, sopsManifest.Spec.AdoptionPolicy, liveObject) {
+			// waits for the object to be annotated or removed, which isn't watched
+			return lang.ErrSopsManifestObjectNotOwned,
+				missingReferenceError(fmt.Errorf("%s %s already exists and is not owned by the sopsmanifest", object.GetKind(), object.GetName()))
+		}
+		if err := backupAdoptedObject(ctx, r.Client, r.Log, liveObject); err != nil {
+			return lang.ErrSopsManifestBackupFailed, err
+		}
+		// the apply merges owner references by uid, so the ones of the
+		// previous owners, e.g. another controller, are replaced beforehand
+		if ownerReferencesPatch := adoptionOwnerReferencesPatch(liveObject, object); ownerReferencesPatch != nil {
+			if err := r.Patch(ctx, liveObject, client.RawPatch(types.MergePatchType, ownerReferencesPatch), client.FieldOwner(sopsSecretFieldManager)); err != nil {
+				return lang.ErrSopsManifestApplyFailed, err
+			}
+		}
+	}
+
+	if err := r.Patch(ctx, object, client.Apply, client.FieldOwner(sopsSecretFieldManager), client.ForceOwnership); err != nil {
+		return lang.ErrSopsManifestApplyFailed, err
+	}
+
+	if exists && !adopting && r.Recorder != nil &&
+		sopsManifest.Status.DataHash == dataHash && object.GetResourceVersion() != liveObject.GetResourceVersion() {
+		r.Recorder.Eventf(sopsManifest, corev1.EventTypeWarning, SopsSecretReasonDriftCorrected,
+			"%s %s drifted and was restored", object.GetKind(), object.GetName())
+	}
+	return "", nil
+}
+
+// adoptionOwnerReferencesPatch returns a merge patch setting the owner
+// references of the applied object on an adopted live object, or nil when
+// the live object has no other owner
+func adoptionOwnerReferencesPatch(liveObject, object metav1.Object) []byte {
+	for _, ownerReference := range liveObject.GetOwnerReferences() {
+		if !hasOwnerReference(object, ownerReference) {
+			patch, _ := json.Marshal(map[string]interface{}{
+				"metadata": map[string]interface{}{"ownerReferences": object.GetOwnerReferences()},
+			})
+			return patch
+		}
+	}
+	return nil
+}
+
+// releaseObject deletes, or orphans when asked to, the object of ref once it
+// is no longer the object of the SopsManifest. Objects the SopsManifest
+// doesn't control are left alone.
+func (r *SopsManifestReconciler) releaseObject(
+	ctx context.Context,
+	sopsManifest *gitopssecretsnappcloudiov1alpha1.SopsManifest,
+	ref *gitopssecretsnappcloudiov1alpha1.ManifestObjectReference,
+	orphan bool,
+) error {
+	if ref == nil {
+		return nil
+	}
+	object := &unstructured.Unstructured{}
+	object.SetAPIVersion(ref.APIVersion)
+	object.SetKind(ref.Kind)
+	err := r.Get(ctx, types.NamespacedName{Namespace: sopsManifest.Namespace, Name: ref.Name}, object)
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !metav1.IsControlledBy(object, sopsManifest) {
+		return nil
+	}
+	if orphan {
+		return orphanObject(ctx, r.Client, r.Log, sopsManifest, object)
+	}
+
+	r.Log.Info(
+		"Deleting the previously applied object",
+		"kind", ref.Kind,
+		"object", ref.Name,
+		"namespace", sopsManifest.Namespace,
+	)
+	uid := object.GetUID()
+	return client.IgnoreNotFound(r.Delete(ctx, object, client.Preconditions{UID: &uid}))
+}
+
+// isManifestObject checks whether ref points to object, whichever the version
+// of their group
+func isManifestObject(ref *gitopssecretsnappcloudiov1alpha1.ManifestObjectReference, object *unstructured.Unstructured) bool {
+	groupVersion, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return false
+	}
+	return groupVersion.WithKind(ref.Kind).GroupKind() == object.GroupVersionKind().GroupKind() && ref.Name == object.GetName()
+}
+
+// checkKindAllowed fails manifests of kinds outside the allowlist or cluster
+// scoped ones for good, and polls for kinds whose CRD isn't installed yet
+func (r *SopsManifestReconciler) checkKindAllowed(gvk schema.GroupVersionKind) error {
+	allowed := false
+	for _, allowedKind := range r.AllowedKinds {
+		allowed = allowed || allowedKind == gvk
+	}
+	if !allowed {
+		return cryptoError(fmt.Errorf("%s is not in the allowed kinds of the operator", gvk))
+	}
+	mapping, err := r.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		return missingReferenceError(err)
+	}
+	if err != nil {
+		return err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return cryptoError(fmt.Errorf("%s is not namespaced", gvk))
+	}
+	return nil
+}
+
+// parseSopsManifest reads the single object of a decrypted manifest, which
+// has to be named and belong to namespace when it sets one
+func parseSopsManifest(cleartext []byte, namespace string) (*unstructured.Unstructured, error) {
+	jsonData, err := yaml.YAMLToJSON(cleartext)
+	if err != nil {
+		return nil, err
+	}
+	object := &unstructured.Unstructured{}
+	if err := object.UnmarshalJSON(jsonData); err != nil {
+		return nil, err
+	}
+	if object.IsList() {
+		return nil, fmt.Errorf("manifest holds a list, not a single object")
+	}
+	if object.GetName() == "" {
+		return nil, fmt.Errorf("manifest has no metadata.name")
+	}
+	if object.GetNamespace() != "" && object.GetNamespace() != namespace {
+		return nil, fmt.Errorf("manifest belongs to namespace %s", object.GetNamespace())
+	}
+	object.SetNamespace(namespace)
+
+	// fields of a live object which can't be applied
+	object.SetResourceVersion("")
+	object.SetUID("")
+	object.SetCreationTimestamp(metav1.Time{})
+	object.SetManagedFields(nil)
+	object.SetOwnerReferences(nil)
+	unstructured.RemoveNestedField(object.Object, "status")
+	return object, nil
+}
+
+// ParseAllowedKinds parses a comma separated list of group/version/Kind,
+// or version/Kind for the core group, e.g. v1/ConfigMap,argoproj.io/v1alpha1/Application
+func ParseAllowedKinds(kinds string) ([]schema.GroupVersionKind, error) {
+	var allowedKinds []schema.GroupVersionKind
+	for _, kind := range strings.Split(kinds, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind == "" {
+			continue
+		}
+		separator := strings.LastIndex(kind, "/")
+		if separator <= 0 || separator == len(kind)-1 {
+			return nil, fmt.Errorf("kind %s is not in the group/version/Kind form", kind)
+		}
+		groupVersion, err := schema.ParseGroupVersion(kind[:separator])
+		if err != nil {
+			return nil, err
+		}
+		allowedKinds = append(allowedKinds, groupVersion.WithKind(kind[separator+1:]))
+	}
+	return allowedKinds, nil
+}
+
+// SetupWithManager sets up the controller with the Manager. Every allowed
+// kind is watched, so the operator needs to list and watch all of them, on
+// top of updating and deleting the objects it releases.
+func (r *SopsManifestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	manifestBuilder := ctrl.NewControllerManagedBy(mgr).
+		For(&gitopssecretsnappcloudiov1alpha1.SopsManifest{}, builder.WithPredicates(specChangedPredicate)).
+		Watches(&gitopssecretsnappcloudiov1alpha1.GPGKey{}, gpgKeyReferrersHandler(r.Client, r.Log, func() client.ObjectList {
+			return &gitopssecretsnappcloudiov1alpha1.SopsManifestList{}
+		}))
+	for _, allowedKind := range r.AllowedKinds {
+		object := &unstructured.Unstructured{}
+		object.SetGroupVersionKind(allowedKind)
+		manifestBuilder = manifestBuilder.Watches(object, handler.EnqueueRequestForOwner(mgr.GetScheme(), mgr.GetRESTMapper(),
+			&gitopssecretsnappcloudiov1alpha1.SopsManifest{}, handler.OnlyControllerOwner()))
+	}
+	return manifestBuilder.Complete(r)
+}
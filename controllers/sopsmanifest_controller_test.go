@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+	"github.com/snapp-incubator/sops-operator/lang"
+)
+
+var _ = Describe("SopsManifest", func() {
+	It("Should parse the allowed kinds of the core and named groups", func() {
+		allowedKinds, err := ParseAllowedKinds("v1/ConfigMap, argoproj.io/v1alpha1/Application")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(allowedKinds).To(Equal([]schema.GroupVersionKind{
+			{Version: "v1", Kind: "ConfigMap"},
+			{Group: "argoproj.io", Version: "v1alpha1", Kind: "Application"},
+		}))
+
+		_, err = ParseAllowedKinds("ConfigMap")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should place the object in the namespace of the SopsManifest", func() {
+		object, err := parseSopsManifest([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n  resourceVersion: \"42\"\ndata:\n  url: https://example.com\n"), "team")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(object.GetNamespace()).To(Equal("team"))
+		Expect(object.GetResourceVersion()).To(BeEmpty())
+		Expect(object.GroupVersionKind()).To(Equal(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}))
+	})
+
+	It("Should reject objects of other namespaces", func() {
+		_, err := parseSopsManifest([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n  namespace: kube-system\n"), "team")
+		Expect(err).To(HaveOccurred())
+	})
+
+	testScheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(testScheme)
+	_ = gitopssecretsnappcloudiov1alpha1.AddToScheme(testScheme)
+
+	newSopsManifest := func(adoptionPolicy string) *gitopssecretsnappcloudiov1alpha1.SopsManifest {
+		return &gitopssecretsnappcloudiov1alpha1.SopsManifest{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team", UID: "b2c4e6f8-0000-0000-0000-000000000000"},
+			Spec:       gitopssecretsnappcloudiov1alpha1.SopsManifestSpec{AdoptionPolicy: adoptionPolicy},
+		}
+	}
+	newConfigMap := func(name string, owner *gitopssecretsnappcloudiov1alpha1.SopsManifest) *corev1.ConfigMap {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team", UID: "a1b2c3d4-0000-0000-0000-000000000000"},
+			Data:       map[string]string{"url": "https://example.com"},
+		}
+		if owner != nil {
+			Expect(controllerutil.SetControllerReference(owner, configMap, testScheme)).To(Succeed())
+		}
+		return configMap
+	}
+	appliedConfigMap := func(sopsManifest *gitopssecretsnappcloudiov1alpha1.SopsManifest) *unstructured.Unstructured {
+		object, err := parseSopsManifest([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\ndata:\n  url: https://example.org\n"), "team")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(controllerutil.SetControllerReference(sopsManifest, object, testScheme)).To(Succeed())
+		return object
+	}
+	// the fake client can't apply, so patches are only recorded
+	newReconciler := func(calls *[]string, objects ...client.Object) *SopsManifestReconciler {
+		recordingClient := interceptor.NewClient(fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objects...).Build(), interceptor.Funcs{
+			Patch: func(_ context.Context, _ client.WithWatch, _ client.Object, patch client.Patch, _ ...client.PatchOption) error {
+				*calls = append(*calls, string(patch.Type()))
+				return nil
+			},
+		})
+		return &SopsManifestReconciler{Client: recordingClient, Scheme: testScheme, Log: ctrl.Log, Recorder: record.NewFakeRecorder(1)}
+	}
+
+	It("Should back up an annotated object and replace its owners before adopting it", func() {
+		sopsManifest := newSopsManifest("")
+		live := newConfigMap("app", nil)
+		live.Annotations = map[string]string{gitopssecretsnappcloudiov1alpha1.SopsSecretManagedAnnotation: "true"}
+		live.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "app", UID: "other"}}
+		var calls []string
+		reconciler := newReconciler(&calls, live)
+
+		message, err := reconciler.applyObject(context.Background(), sopsManifest, appliedConfigMap(sopsManifest), "hash")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(message).To(BeEmpty())
+		Expect(calls).To(Equal([]string{string(types.MergePatchType), string(types.ApplyPatchType)}))
+
+		backup := &corev1.Secret{}
+		Expect(reconciler.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: "app-backup-a1b2c3d4"}, backup)).To(Succeed())
+		Expect(backup.Annotations[gitopssecretsnappcloudiov1alpha1.SopsSecretBackupOfAnnotation]).To(Equal("app (ConfigMap)"))
+		Expect(string(backup.Data[backupManifestKey])).To(ContainSubstring("url: https://example.com"))
+	})
+
+	It("Should not adopt an object its adoption policy excludes", func() {
+		sopsManifest := newSopsManifest(gitopssecretsnappcloudiov1alpha1.AdoptionPolicyNever)
+		live := newConfigMap("app", nil)
+		live.Annotations = map[string]string{gitopssecretsnappcloudiov1alpha1.SopsSecretManagedAnnotation: "true"}
+		var calls []string
+
+		message, err := newReconciler(&calls, live).applyObject(context.Background(), sopsManifest, appliedConfigMap(sopsManifest), "hash")
+		Expect(classifyError(err)).To(Equal(errorClassMissingReference))
+		Expect(message).To(Equal(lang.ErrSopsManifestObjectNotOwned))
+		Expect(calls).To(BeEmpty())
+	})
+
+	It("Should delete or orphan the object of a renamed manifest", func() {
+		sopsManifest := newSopsManifest("")
+		previous := &gitopssecretsnappcloudiov1alpha1.ManifestObjectReference{APIVersion: "v1", Kind: "ConfigMap", Name: "old-app"}
+		Expect(isManifestObject(previous, appliedConfigMap(sopsManifest))).To(BeFalse())
+		Expect(isManifestObject(&gitopssecretsnappcloudiov1alpha1.ManifestObjectReference{APIVersion: "v1", Kind: "ConfigMap", Name: "app"},
+			appliedConfigMap(sopsManifest))).To(BeTrue())
+
+		var calls []string
+		reconciler := newReconciler(&calls, newConfigMap("old-app", sopsManifest))
+		Expect(reconciler.releaseObject(context.Background(), sopsManifest, previous, true)).To(Succeed())
+		orphaned := &corev1.ConfigMap{}
+		Expect(reconciler.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: "old-app"}, orphaned)).To(Succeed())
+		Expect(orphaned.OwnerReferences).To(BeEmpty())
+
+		reconciler = newReconciler(&calls, newConfigMap("old-app", sopsManifest))
+		Expect(reconciler.releaseObject(context.Background(), sopsManifest, previous, false)).To(Succeed())
+		err := reconciler.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: "old-app"}, &corev1.ConfigMap{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("Should leave a previous object it doesn't control", func() {
+		sopsManifest := newSopsManifest("")
+		previous := &gitopssecretsnappcloudiov1alpha1.ManifestObjectReference{APIVersion: "v1", Kind: "ConfigMap", Name: "old-app"}
+		var calls []string
+		reconciler := newReconciler(&calls, newConfigMap("old-app", nil))
+		Expect(reconciler.releaseObject(context.Background(), sopsManifest, previous, false)).To(Succeed())
+		Expect(reconciler.Get(context.Background(), types.NamespacedName{Namespace: "team", Name: "old-app"}, &corev1.ConfigMap{})).To(Succeed())
+	})
+})
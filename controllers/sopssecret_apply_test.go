@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+)
+
+var _ = Describe("Child secret server-side apply", func() {
+	newLiveSecret := func() *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "example-secret",
+				Labels: map[string]string{"app": "example"},
+				ManagedFields: []metav1.ManagedFieldsEntry{{
+					Manager:   sopsSecretFieldManager,
+					Operation: metav1.ManagedFieldsOperationApply,
+					FieldsV1:  &metav1.FieldsV1{Raw: []byte(`{"f:data":{"f:old-key":{},"f:key":{}}}`)},
+				}},
+			},
+			Type: corev1.SecretTypeOpaque,
+			Data: map[string][]byte{
+				"key":     []byte("value"),
+				"old-key": []byte("value"),
+				"ca.crt":  []byte("injected"),
+			},
+		}
+	}
+	template := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "example-secret", Labels: map[string]string{"app": "example"}},
+		Type:       corev1.SecretTypeOpaque,
+		StringData: map[string]string{"key": "value"},
+	}
+
+	It("Should not write a secret whose data is unchanged", func() {
+		live := newLiveSecret()
+		delete(live.Data, "old-key")
+		live.ManagedFields = nil
+		applied := newAppliedKubeSecret(template, false)
+		Expect(kubeSecretNeedsApply(live, applied, false)).To(BeFalse())
+		Expect(kubeSecretCleanupPatch(live, applied, false, false)).To(BeNil())
+	})
+
+	It("Should only remove the keys it wrote when merging", func() {
+		applied := newAppliedKubeSecret(template, false)
+		Expect(string(kubeSecretCleanupPatch(newLiveSecret(), applied, false, false))).To(Equal(`{"data":{"old-key":null}}`))
+	})
+
+	It("Should remove every foreign key when replacing", func() {
+		applied := newAppliedKubeSecret(template, true)
+		Expect(string(kubeSecretCleanupPatch(newLiveSecret(), applied, true, false))).To(Equal(`{"data":{"ca.crt":null,"old-key":null}}`))
+	})
+
+	It("Should report edited and extra keys as drifted when replacing", func() {
+		live := newLiveSecret()
+		live.Data["key"] = []byte("edited")
+		applied := newAppliedKubeSecret(template, true)
+		Expect(driftedDataKeys(live, applied, true)).To(Equal([]string{"ca.crt", "key", "old-key"}))
+	})
+
+	It("Should recreate a secret whose type changed and skip the cleanup", func() {
+		var calls []string
+		recordingClient := interceptor.NewClient(fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), interceptor.Funcs{
+			Delete: func(context.Context, client.WithWatch, client.Object, ...client.DeleteOption) error {
+				calls = append(calls, "delete")
+				return nil
+			},
+			Patch: func(_ context.Context, _ client.WithWatch, _ client.Object, patch client.Patch, _ ...client.PatchOption) error {
+				calls = append(calls, string(patch.Type()))
+				return nil
+			},
+		})
+		applied := newAppliedKubeSecret(template, true)
+		applied.Type = corev1.SecretTypeBasicAuth
+
+		refreshed, changed, err := applyChildSecret(context.Background(), recordingClient, ctrl.Log, newLiveSecret(), applied, true, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(refreshed).To(Equal(applied))
+		Expect(calls).To(Equal([]string{"delete", "application/apply-patch+yaml"}))
+	})
+
+	It("Should not write an up to date secret", func() {
+		live := newLiveSecret()
+		delete(live.Data, "old-key")
+		delete(live.Data, "ca.crt")
+		applied := newAppliedKubeSecret(template, true)
+		patched := false
+		recordingClient := interceptor.NewClient(fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(), interceptor.Funcs{
+			Patch: func(context.Context, client.WithWatch, client.Object, client.Patch, ...client.PatchOption) error {
+				patched = true
+				return nil
+			},
+		})
+
+		refreshed, changed, err := applyChildSecret(context.Background(), recordingClient, ctrl.Log, live, applied, true, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeFalse())
+		Expect(patched).To(BeFalse())
+		Expect(refreshed).To(Equal(live))
+	})
+
+	It("Should merge into a secret of another controller without claiming it", func() {
+		isController := true
+		sopsSecret := &gitopssecretsnappcloudiov1alpha1.SopsSecret{
+			ObjectMeta: metav1.ObjectMeta{Name: "example-secret", UID: "sopssecret-uid"},
+			Spec:       gitopssecretsnappcloudiov1alpha1.SopsSecretSpec{MergeStrategy: gitopssecretsnappcloudiov1alpha1.MergeStrategyMerge},
+		}
+		live := newLiveSecret()
+		live.OwnerReferences = []metav1.OwnerReference{{APIVersion: "cert-manager.io/v1", Kind: "Certificate", Name: "example", UID: "certificate-uid", Controller: &isController}}
+		ownedTemplate := template.DeepCopy()
+		ownedTemplate.OwnerReferences = []metav1.OwnerReference{{APIVersion: "gitopssecret.snappcloud.io/v1alpha1", Kind: "SopsSecret", Name: "example-secret", UID: "sopssecret-uid", Controller: &isController}}
+		applied := newAppliedKubeSecret(ownedTemplate, false)
+
+		yieldToLiveController(applied, live)
+		Expect(applied.OwnerReferences).To(HaveLen(1))
+		Expect(applied.OwnerReferences[0].Controller).To(BeNil())
+		Expect(ownedTemplate.OwnerReferences[0].Controller).To(Equal(&isController))
+		Expect(isChildOf(sopsSecret, live)).To(BeFalse())
+
+		live.OwnerReferences = append(live.OwnerReferences, applied.OwnerReferences[0])
+		Expect(isChildOf(sopsSecret, live)).To(BeTrue())
+		sopsSecret.Spec.MergeStrategy = gitopssecretsnappcloudiov1alpha1.MergeStrategyReplace
+		Expect(isChildOf(sopsSecret, live)).To(BeFalse())
+	})
+})
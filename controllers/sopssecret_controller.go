@@ -17,11 +17,15 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha512"
 	"encoding/json"
 	"fmt"
 	"github.com/snapp-incubator/sops-operator/lang"
 	"io/ioutil"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -31,18 +35,17 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
 	sopsaes "go.mozilla.org/sops/v3/aes"
 	sopslogging "go.mozilla.org/sops/v3/logging"
-	sopsdotenv "go.mozilla.org/sops/v3/stores/dotenv"
-	sopsjson "go.mozilla.org/sops/v3/stores/json"
-	sopsyaml "go.mozilla.org/sops/v3/stores/yaml"
 	corev1 "k8s.io/api/core/v1"
-	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -50,6 +53,20 @@ var (
 	unwantedAnnotations = []string{
 		"kubectl.kubernetes.io/last-applied-configuration",
 	}
+
+	// sopsSecretFieldManager owns the fields server-side applied to child secrets
+	sopsSecretFieldManager = "sops-operator"
+
+	// errSopsSecretMACMismatch when the decrypted files and stringData don't match the sops mac
+	errSopsSecretMACMismatch = fmt.Errorf("sops mac of files and stringData mismatch")
+
+	// errSopsSecretMACUnverifiable when the sops mac of a SopsSecret covers
+	// values the operator can't reproduce, as it wasn't encrypted with
+	// mac_only_encrypted
+	errSopsSecretMACUnverifiable = fmt.Errorf("sops mac of the sopssecret can't be verified without mac_only_encrypted")
+
+	// SopsSecretReasonDriftCorrected is the event reason for restoring a child secret edited by hand
+	SopsSecretReasonDriftCorrected = "DriftCorrected"
 )
 
 // SopsSecretReconciler reconciles a SopsSecret object
@@ -57,12 +74,14 @@ type SopsSecretReconciler struct {
 	client.Client
 	Scheme       *runtime.Scheme
 	Log          logr.Logger
+	Recorder     record.EventRecorder
 	RequeueAfter int64
 }
 
 //+kubebuilder:rbac:groups=gitopssecret.snappcloud.io,resources=sopssecrets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=gitopssecret.snappcloud.io,resources=sopssecrets/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=gitopssecret.snappcloud.io,resources=sopssecrets/finalizers,verbs=update
+//+kubebuilder:rbac:groups=gitopssecret.snappcloud.io,resources=gpgkeygrants,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -83,107 +102,381 @@ func (r *SopsSecretReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return reconcile.Result{}, err
 	}
 
-	referencedGPGKey, rescheduleReconcileLoop := r.getGPGKeyRefNameObj(ctx, req, encryptedSopsSecret)
-	if rescheduleReconcileLoop {
-		return reconcile.Result{Requeue: true, RequeueAfter: time.Duration(r.RequeueAfter) * time.Minute}, nil
+	finishReconcileLoop, err = r.applyDeletionPolicy(ctx, req, encryptedSopsSecret)
+	if finishReconcileLoop {
+		if err != nil {
+			return r.requeueOnError(req, err)
+		}
+		return reconcile.Result{}, nil
+	}
+
+	referencedGPGKeys, err := r.getGPGKeyRefObjs(ctx, req, encryptedSopsSecret)
+	if err != nil {
+		return r.requeueOnError(req, err)
 	}
 
 	if r.isSecretSuspended(encryptedSopsSecret, req) {
 		return reconcile.Result{}, nil
 	}
 
-	plainTextSopsSecret, rescheduleReconcileLoop := r.decryptSopsSecret(encryptedSopsSecret, referencedGPGKey.Spec.Passphrase)
-	if rescheduleReconcileLoop {
-		return reconcile.Result{Requeue: true, RequeueAfter: time.Duration(r.RequeueAfter) * time.Minute}, nil
+	source, sourceRevision, err := r.fetchSopsSource(ctx, encryptedSopsSecret)
+	if err != nil {
+		r.Log.Info("Fetching sourceRef error", "sopssecret", req.NamespacedName, "error", err)
+		encryptedSopsSecret.Status.Health = lang.SopsUnHealthyStatus
+		encryptedSopsSecret.Status.Message = lang.ErrSopsSecretSourceFetchFailed
+		_ = r.Status().Update(ctx, encryptedSopsSecret)
+		return r.requeueOnError(req, err)
+	}
+
+	plainTextSopsSecret, sourceCleartext, err := r.decryptSopsSecret(ctx, encryptedSopsSecret, referencedGPGKeys, source)
+	if err != nil {
+		return r.requeueOnError(req, err)
 	}
 
 	// Iterate over secret templates
 	r.Log.Info("Entering template data loop", "sopssecret", req.NamespacedName)
-	stringData := plainTextSopsSecret.Spec.StringData
+	stringData, err := plainTextSopsSecret.Spec.StringDataValues()
+	if err != nil {
+		r.Log.Info("Invalid stringData value", "sopssecret", req.NamespacedName, "error", err)
+		encryptedSopsSecret.Status.Health = lang.SopsUnHealthyStatus
+		encryptedSopsSecret.Status.Message = lang.ErrSopsSecretStringDataStructured
+		_ = r.Status().Update(ctx, encryptedSopsSecret)
+		return r.requeueOnError(req, cryptoError(err))
+	}
+	spec := &plainTextSopsSecret.Spec
+	childSources := []struct {
+		name    string
+		message string
+		expand  func() (map[string]string, error)
+	}{
+		{"files", lang.ErrSopsSecretFilesRenderFailed, func() (map[string]string, error) {
+			return renderSecretFiles(spec.Files)
+		}},
+		{"document", lang.ErrSopsSecretDocumentExpandFailed, func() (map[string]string, error) {
+			return expandSopsDocument(spec.Document)
+		}},
+		{"sourceRef", lang.ErrSopsSecretSourceExpandFailed, func() (map[string]string, error) {
+			return expandSopsSource(spec.SourceRef, sourceCleartext)
+		}},
+		{"typed helper", lang.ErrSopsSecretHelpersExpandFailed, func() (map[string]string, error) {
+			return expandSecretHelpers(spec)
+		}},
+	}
+	for _, childSource := range childSources {
+		entries, err := childSource.expand()
+		if err == nil {
+			err = mergeChildEntries(stringData, entries, childSource.name)
+		}
+		if err != nil {
+			r.Log.Info("Expanding child secret entries error", "sopssecret", req.NamespacedName, "source", childSource.name, "error", err)
+			encryptedSopsSecret.Status.Health = lang.SopsUnHealthyStatus
+			encryptedSopsSecret.Status.Message = childSource.message
+			_ = r.Status().Update(ctx, encryptedSopsSecret)
+			return r.requeueOnError(req, cryptoError(err))
+		}
+	}
+	keys := make(map[string]bool, len(stringData))
+	for key := range stringData {
+		keys[key] = true
+	}
+	secretType := plainTextSopsSecret.Spec.SecretType()
+	if missing := gitopssecretsnappcloudiov1alpha1.MissingSecretKeys(secretType, keys); len(missing) > 0 {
+		r.Log.Info("Keys required by the secret type are missing", "sopssecret", req.NamespacedName, "type", secretType, "keys", missing)
+		encryptedSopsSecret.Status.Health = lang.SopsUnHealthyStatus
+		encryptedSopsSecret.Status.Message = lang.ErrSopsSecretTypeKeysMissing
+		_ = r.Status().Update(ctx, encryptedSopsSecret)
+		return r.requeueOnError(req, cryptoError(fmt.Errorf("secret type %s requires the keys %v", secretType, missing)))
+	}
 
-	kubeSecretFromTemplate, rescheduleReconcileLoop := r.newKubeSecretFromTemplate(req, encryptedSopsSecret, plainTextSopsSecret, &stringData)
-	if rescheduleReconcileLoop {
-		return reconcile.Result{Requeue: true, RequeueAfter: time.Duration(r.RequeueAfter) * time.Minute}, nil
+	kubeSecretFromTemplate, err := r.newKubeSecretFromTemplate(req, encryptedSopsSecret, plainTextSopsSecret, &stringData)
+	if err != nil {
+		return r.requeueOnError(req, err)
 	}
 
-	kubeSecretInCluster, rescheduleReconcileLoop := r.getSecretFromClusterOrCreateFromTemplate(ctx, req, encryptedSopsSecret, kubeSecretFromTemplate)
-	if rescheduleReconcileLoop {
-		return reconcile.Result{Requeue: true, RequeueAfter: time.Duration(r.RequeueAfter) * time.Minute}, nil
+	kubeSecretInCluster, err := r.getSecretFromClusterOrCreateFromTemplate(ctx, req, encryptedSopsSecret, kubeSecretFromTemplate)
+	if err != nil {
+		return r.requeueOnError(req, err)
 	}
 
-	rescheduleReconcileLoop = r.isKubeSecretManagedOrAnnotatedToBeManaged(req, encryptedSopsSecret, kubeSecretInCluster)
-	if rescheduleReconcileLoop {
-		return reconcile.Result{Requeue: true, RequeueAfter: time.Duration(r.RequeueAfter) * time.Minute}, nil
+	if err := r.isKubeSecretManagedOrAnnotatedToBeManaged(req, encryptedSopsSecret, kubeSecretInCluster); err != nil {
+		return r.requeueOnError(req, err)
 	}
 
-	rescheduleReconcileLoop = r.refreshKubeSecretIfNeeded(ctx, req, encryptedSopsSecret, kubeSecretFromTemplate, kubeSecretInCluster)
-	if rescheduleReconcileLoop {
-		return reconcile.Result{Requeue: true, RequeueAfter: time.Duration(r.RequeueAfter) * time.Minute}, nil
+	if err := r.refreshKubeSecretIfNeeded(ctx, req, encryptedSopsSecret, kubeSecretFromTemplate, kubeSecretInCluster); err != nil {
+		return r.requeueOnError(req, err)
 	}
 
+	// the message is kept as set by warnExpiredGPGKeys
 	encryptedSopsSecret.Status.Health = lang.SopsHealthyStatus
-	encryptedSopsSecret.Status.Message = ""
+	if dataHash := kubeSecretFromTemplate.Annotations[gitopssecretsnappcloudiov1alpha1.SopsSecretDataHashAnnotation]; dataHash != encryptedSopsSecret.Status.DataHash {
+		now := metav1.Now()
+		encryptedSopsSecret.Status.DataHash = dataHash
+		encryptedSopsSecret.Status.LastSyncedAt = &now
+	}
+	encryptedSopsSecret.Status.SopsLastModified = encryptedSopsSecret.Sops.LastModified
+	encryptedSopsSecret.Status.SourceRevision = sourceRevision
 	_ = r.Status().Update(context.Background(), encryptedSopsSecret)
 
 	r.Log.Info("SopsSecret is Healthy", "sopssecret", req.NamespacedName)
+	if refreshInterval := encryptedSopsSecret.Spec.RefreshInterval; refreshInterval != nil && refreshInterval.Duration > 0 {
+		return ctrl.Result{RequeueAfter: refreshInterval.Duration}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
-func (r *SopsSecretReconciler) getGPGKeyRefNameObj(
+// mergeChildEntries adds the entries expanded from source to the stringData
+// of the child secret, failing when one of them is already set
+func mergeChildEntries(stringData, entries map[string]string, source string) error {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, ok := stringData[key]; ok {
+			return fmt.Errorf("%s entry %s is already set by stringData or another source", source, key)
+		}
+	}
+	for key, value := range entries {
+		stringData[key] = value
+	}
+	return nil
+}
+
+// getGPGKeyRefObjs fetches the referenced GPGKeys in order, skipping the ones
+// that can't be fetched, belong to another namespace without a GPGKeyGrant or
+// don't allow the SopsSecret
+func (r *SopsSecretReconciler) getGPGKeyRefObjs(
 	ctx context.Context,
 	req ctrl.Request,
 	encryptedSopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret,
-) (*gitopssecretsnappcloudiov1alpha1.GPGKey, bool) {
-	gpgkey := &gitopssecretsnappcloudiov1alpha1.GPGKey{}
-	namespacedName := types.NamespacedName{Namespace: req.Namespace, Name: encryptedSopsSecret.Spec.GPGKeyRefName}
-	err := r.Get(ctx, namespacedName, gpgkey)
+) ([]*gitopssecretsnappcloudiov1alpha1.GPGKey, error) {
+	gpgkeys, message, err := fetchGPGKeyRefs(ctx, r.Client, r.Log, encryptedSopsSecret, encryptedSopsSecret.Spec.GetGPGKeyRefs())
 	if err != nil {
-		r.Log.Info("Error fetching GPGKey", "GPGKey", namespacedName, "error", err)
 		encryptedSopsSecret.Status.Health = lang.SopsUnHealthyStatus
-		encryptedSopsSecret.Status.Message = lang.ErrGPGKeyRefFetchFail
+		encryptedSopsSecret.Status.Message = message
 		_ = r.Status().Update(ctx, encryptedSopsSecret)
-		return nil, true
+		return nil, err
 	}
-	return gpgkey, false
+	return gpgkeys, nil
 }
 
+// fetchGPGKeyRefs returns the usable GPGKeys of refs for object, or the
+// status message and error explaining why none of them is usable
+func fetchGPGKeyRefs(
+	ctx context.Context,
+	c client.Client,
+	logger logr.Logger,
+	object client.Object,
+	refs []gitopssecretsnappcloudiov1alpha1.GPGKeyReference,
+) ([]*gitopssecretsnappcloudiov1alpha1.GPGKey, string, error) {
+	var gpgkeys []*gitopssecretsnappcloudiov1alpha1.GPGKey
+	var lastErr error
+	message := lang.ErrGPGKeyRefFetchFail
+	for _, ref := range refs {
+		granted, err := gitopssecretsnappcloudiov1alpha1.IsGPGKeyReferenceGranted(ctx, c, object.GetNamespace(), ref)
+		if err != nil {
+			logger.Info("Error fetching GPGKeyGrants", "GPGKey", ref.String(), "error", err)
+			lastErr = err
+			continue
+		}
+		if !granted {
+			logger.Info("GPGKey is not granted to the namespace", "GPGKey", ref.String(), "namespace", object.GetNamespace())
+			message = lang.ErrGPGKeyRefNotGranted
+			continue
+		}
+
+		gpgkey := &gitopssecretsnappcloudiov1alpha1.GPGKey{}
+		namespacedName := types.NamespacedName{Namespace: object.GetNamespace(), Name: ref.Name}
+		if ref.Namespace != "" {
+			namespacedName.Namespace = ref.Namespace
+		}
+		if err := c.Get(ctx, namespacedName, gpgkey); err != nil {
+			logger.Info("Error fetching GPGKey", "GPGKey", namespacedName, "error", err)
+			lastErr = missingReferenceError(err)
+			continue
+		}
+		if allowed, err := gpgkey.Spec.AllowedSecrets.Allows(object); err != nil || !allowed {
+			logger.Info("GPGKey does not allow the object", "GPGKey", namespacedName, "object", client.ObjectKeyFromObject(object), "error", err)
+			message = lang.ErrGPGKeyRefNotAllowed
+			continue
+		}
+		// the key is imported again after a restart of the operator, which
+		// isn't an event the referrers of the key can wait for
+		if gpgkey.Status.Fingerprint != "" && !hasGPGSecretKey(gpgkey.Status.Fingerprint) {
+			logger.Info("GPGKey is not imported yet", "GPGKey", namespacedName)
+			lastErr = missingReferenceError(fmt.Errorf("gpgkey %s is not imported yet", namespacedName))
+			continue
+		}
+		gpgkeys = append(gpgkeys, gpgkey)
+	}
+	if len(gpgkeys) == 0 {
+		if lastErr == nil || classifyError(lastErr) == errorClassMissingReference {
+			lastErr = missingReferenceError(fmt.Errorf("%s", message))
+		}
+		return nil, message, lastErr
+	}
+	return gpgkeys, "", nil
+}
+
+// decryptSopsSecret tries the referenced GPGKeys in order and records the
+// first one that decrypts the SopsSecret, and the file of its sourceRef, in
+// its status
 func (r *SopsSecretReconciler) decryptSopsSecret(
+	ctx context.Context,
 	encryptedSopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret,
-	passphrase string,
-) (*gitopssecretsnappcloudiov1alpha1.SopsSecret, bool) {
-	decryptedSopsSecret, err := decryptSopsSecretInstance(encryptedSopsSecret, r.Log, passphrase)
+	referencedGPGKeys []*gitopssecretsnappcloudiov1alpha1.GPGKey,
+	source []byte,
+) (*gitopssecretsnappcloudiov1alpha1.SopsSecret, []byte, error) {
+	var decryptedSopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret
+	var sourceCleartext []byte
+	decryptingGPGKeys, gpgKeyRef, message, err := decryptWithGPGKeys(ctx, r.Client, r.Log, encryptedSopsSecret.Namespace, referencedGPGKeys,
+		sopsSecretKeyGroups(encryptedSopsSecret, source) > 1, lang.ErrSopsSecretMACMismatch,
+		func(passphrases ...string) (err error) {
+			decryptedSopsSecret, sourceCleartext, err = decryptSopsSecretInstance(encryptedSopsSecret, source, r.Log, passphrases...)
+			return err
+		})
 	if err != nil {
 		encryptedSopsSecret.Status.Health = lang.SopsUnHealthyStatus
-		encryptedSopsSecret.Status.Message = lang.ErrSopsSecretDecryptionFailed
+		encryptedSopsSecret.Status.Message = message
 
 		// will not process plainTextSopsSecret error as we are already in error mode here
 		_ = r.Status().Update(context.Background(), encryptedSopsSecret)
 
-		// Failed to decrypt, re-schedule reconciliation in 5 minutes
-		return nil, true
+		// a crypto error mostly waits for the SopsSecret or a GPGKey to change
+		return nil, nil, err
+	}
+	encryptedSopsSecret.Status.GPGKeyRef = gpgKeyRef
+	r.warnExpiredGPGKeys(encryptedSopsSecret, decryptingGPGKeys...)
+	return decryptedSopsSecret, sourceCleartext, nil
+}
+
+// sopsSecretKeyGroups returns the most key groups of the sops metadata of
+// the SopsSecret, its document and its source, which share the GPGKeys
+func sopsSecretKeyGroups(encryptedSopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret, source []byte) int {
+	keyGroups := len(encryptedSopsSecret.Sops.KeyGroups)
+	if document := encryptedSopsSecret.Spec.Document; document != nil {
+		if documentKeyGroups := sopsDocumentKeyGroups(document.Format, []byte(document.Data)); documentKeyGroups > keyGroups {
+			keyGroups = documentKeyGroups
+		}
+	}
+	if sourceRef := encryptedSopsSecret.Spec.SourceRef; sourceRef != nil && source != nil {
+		if sourceKeyGroups := sopsDocumentKeyGroups(sourceRef.Format, source); sourceKeyGroups > keyGroups {
+			keyGroups = sourceKeyGroups
+		}
+	}
+	return keyGroups
+}
+
+// warnExpiredGPGKeys sets the message of a healthy SopsSecret, which warns
+// when it was decrypted with an expired GPGKey. gpg still decrypts with an
+// expired key, so the expiry would go unnoticed until the key is removed.
+func (r *SopsSecretReconciler) warnExpiredGPGKeys(
+	encryptedSopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret,
+	gpgKeys ...*gitopssecretsnappcloudiov1alpha1.GPGKey,
+) {
+	var expired []string
+	for _, gpgKey := range gpgKeys {
+		if isGPGKeyExpired(gpgKey) {
+			expired = append(expired, gpgKey.Namespace+"/"+gpgKey.Name)
+		}
+	}
+	encryptedSopsSecret.Status.Message = ""
+	if len(expired) == 0 {
+		return
+	}
+	encryptedSopsSecret.Status.Message = lang.WarnGPGKeyExpired
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(encryptedSopsSecret, corev1.EventTypeWarning, GPGKeyReasonExpired,
+		"Decrypted with expired GPGKey %s, re-encrypt it with a valid GPGKey", strings.Join(expired, ", "))
+}
+
+// applyDeletionPolicy keeps the finalizer in line with spec.deletionPolicy and,
+// once the SopsSecret is being deleted, orphans its child secret if asked to
+func (r *SopsSecretReconciler) applyDeletionPolicy(
+	ctx context.Context,
+	req ctrl.Request,
+	encryptedSopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret,
+) (bool, error) {
+	orphan := encryptedSopsSecret.Spec.DeletionPolicy == gitopssecretsnappcloudiov1alpha1.DeletionPolicyOrphan
+
+	if encryptedSopsSecret.DeletionTimestamp.IsZero() {
+		var finalizerChanged bool
+		if orphan {
+			finalizerChanged = controllerutil.AddFinalizer(encryptedSopsSecret, gitopssecretsnappcloudiov1alpha1.SopsSecretFinalizer)
+		} else {
+			finalizerChanged = controllerutil.RemoveFinalizer(encryptedSopsSecret, gitopssecretsnappcloudiov1alpha1.SopsSecretFinalizer)
+		}
+		if finalizerChanged {
+			if err := r.Update(ctx, encryptedSopsSecret); err != nil {
+				return true, err
+			}
+		}
+		return false, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(encryptedSopsSecret, gitopssecretsnappcloudiov1alpha1.SopsSecretFinalizer) {
+		return true, nil
+	}
+	if orphan {
+		if err := r.orphanKubeSecret(ctx, encryptedSopsSecret); err != nil {
+			encryptedSopsSecret.Status.Health = lang.SopsUnHealthyStatus
+			encryptedSopsSecret.Status.Message = lang.ErrSopsSecretCouldNotOrphanChild
+			_ = r.Status().Update(ctx, encryptedSopsSecret)
+
+			r.Log.Info(
+				"Child secret orphaning error",
+				"sopssecret", req.NamespacedName,
+				"error", err,
+			)
+			return true, err
+		}
+	}
+	controllerutil.RemoveFinalizer(encryptedSopsSecret, gitopssecretsnappcloudiov1alpha1.SopsSecretFinalizer)
+	return true, r.Update(ctx, encryptedSopsSecret)
+}
+
+// orphanKubeSecret strips the owner reference and managed annotation from the
+// child secret, so it survives the deletion of its SopsSecret
+func (r *SopsSecretReconciler) orphanKubeSecret(
+	ctx context.Context,
+	encryptedSopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret,
+) error {
+	kubeSecret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Namespace: encryptedSopsSecret.Namespace, Name: encryptedSopsSecret.Name}, kubeSecret)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
 	}
-	return decryptedSopsSecret, false
+	return orphanObject(ctx, r.Client, r.Log, encryptedSopsSecret, kubeSecret)
 }
 
 func (r *SopsSecretReconciler) isKubeSecretManagedOrAnnotatedToBeManaged(
 	req ctrl.Request,
 	encryptedSopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret,
 	kubeSecretInCluster *corev1.Secret,
-) bool {
+) error {
 	// kubeSecretFromTemplate found - perform ownership check
-	if !metav1.IsControlledBy(kubeSecretInCluster, encryptedSopsSecret) && !isAnnotatedToBeManaged(kubeSecretInCluster) {
+	if !isChildOf(encryptedSopsSecret, kubeSecretInCluster) &&
+		!mayManage(encryptedSopsSecret, encryptedSopsSecret.Spec.AdoptionPolicy, kubeSecretInCluster) {
 		encryptedSopsSecret.Status.Health = lang.SopsUnHealthyStatus
 		encryptedSopsSecret.Status.Message = lang.ErrSopsSecretChildNotOwned
 		_ = r.Status().Update(context.Background(), encryptedSopsSecret)
 
+		err := fmt.Errorf("sopssecret has a conflict with existing kubernetes secret resource, potential reasons: target secret already pre-existed or is managed by multiple sops secrets")
 		r.Log.Info(
 			"Child secret is not owned by controller or sopssecret Error",
 			"sopssecret", req.NamespacedName,
-			"error", fmt.Errorf("sopssecret has a conflict with existing kubernetes secret resource, potential reasons: target secret already pre-existed or is managed by multiple sops secrets"),
+			"error", err,
 		)
-		return true
+		// waits for the secret to be annotated or removed, which isn't watched
+		return missingReferenceError(err)
 	}
-	return false
+	return nil
 }
 
 func (r *SopsSecretReconciler) refreshKubeSecretIfNeeded(
@@ -192,44 +485,350 @@ func (r *SopsSecretReconciler) refreshKubeSecretIfNeeded(
 	encryptedSopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret,
 	kubeSecretFromTemplate *corev1.Secret,
 	kubeSecretInCluster *corev1.Secret,
-) bool {
-	copyOfKubeSecretInCluster := kubeSecretInCluster.DeepCopy()
+) error {
+	adopting := !isChildOf(encryptedSopsSecret, kubeSecretInCluster)
+	if adopting {
+		if err := backupAdoptedObject(ctx, r.Client, r.Log, kubeSecretInCluster); err != nil {
+			encryptedSopsSecret.Status.Health = lang.SopsUnHealthyStatus
+			encryptedSopsSecret.Status.Message = lang.ErrSopsSecretChildBackupFailed
+			_ = r.Status().Update(context.Background(), encryptedSopsSecret)
 
-	copyOfKubeSecretInCluster.StringData = kubeSecretFromTemplate.StringData
-	copyOfKubeSecretInCluster.Data = map[string][]byte{}
-	copyOfKubeSecretInCluster.Type = kubeSecretFromTemplate.Type
-	copyOfKubeSecretInCluster.ObjectMeta.Annotations = kubeSecretFromTemplate.ObjectMeta.Annotations
-	copyOfKubeSecretInCluster.ObjectMeta.Labels = kubeSecretFromTemplate.ObjectMeta.Labels
+			r.Log.Info(
+				"Child secret backup error",
+				"sopssecret", req.NamespacedName,
+				"error", err,
+			)
+			return err
+		}
+	}
 
-	removeUnwantedAnnotations(copyOfKubeSecretInCluster)
+	replace := encryptedSopsSecret.Spec.MergeStrategy != gitopssecretsnappcloudiov1alpha1.MergeStrategyMerge
+	appliedKubeSecret := newAppliedKubeSecret(kubeSecretFromTemplate, replace)
+	if !replace {
+		yieldToLiveController(appliedKubeSecret, kubeSecretInCluster)
+	}
 
-	if isAnnotatedToBeManaged(kubeSecretInCluster) {
-		copyOfKubeSecretInCluster.ObjectMeta.OwnerReferences = kubeSecretFromTemplate.ObjectMeta.OwnerReferences
+	// the content of the SopsSecret is unchanged, so the live secret drifted
+	var driftedKeys []string
+	if !adopting && encryptedSopsSecret.Status.DataHash == appliedKubeSecret.Annotations[gitopssecretsnappcloudiov1alpha1.SopsSecretDataHashAnnotation] {
+		driftedKeys = driftedDataKeys(kubeSecretInCluster, appliedKubeSecret, replace)
 	}
+	checksumBefore := secretDataChecksum(kubeSecretInCluster.Data)
+	refreshedKubeSecret, refreshed, err := applyChildSecret(ctx, r.Client, r.Log, kubeSecretInCluster, appliedKubeSecret, replace,
+		adopting || isAnnotatedToBeManaged(kubeSecretInCluster))
+	if err != nil {
+		encryptedSopsSecret.Status.Health = lang.SopsUnHealthyStatus
+		encryptedSopsSecret.Status.Message = lang.ErrSopsSecretCouldNotUpdateChild
+		_ = r.Status().Update(context.Background(), encryptedSopsSecret)
 
-	if !apiequality.Semantic.DeepEqual(kubeSecretInCluster, copyOfKubeSecretInCluster) {
 		r.Log.Info(
-			"Secret already exists and needs to be refreshed",
-			"secret", copyOfKubeSecretInCluster.Name,
-			"namespace", copyOfKubeSecretInCluster.Namespace,
+			"Child secret update error",
+			"sopssecret", req.NamespacedName,
+			"error", err,
 		)
-		if err := r.Update(ctx, copyOfKubeSecretInCluster); err != nil {
-			encryptedSopsSecret.Status.Health = lang.SopsUnHealthyStatus
-			encryptedSopsSecret.Status.Message = lang.ErrSopsSecretCouldNotUpdateChild
-			_ = r.Status().Update(context.Background(), encryptedSopsSecret)
+		return err
+	}
+	if !refreshed {
+		return r.rolloutPendingWorkloads(ctx, req, encryptedSopsSecret, kubeSecretInCluster)
+	}
 
-			r.Log.Info(
-				"Child secret update error",
-				"sopssecret", req.NamespacedName,
-				"error", err,
-			)
-			return true
+	if len(driftedKeys) > 0 && r.Recorder != nil {
+		r.Recorder.Eventf(encryptedSopsSecret, corev1.EventTypeWarning, SopsSecretReasonDriftCorrected,
+			"Child secret %s drifted, restored keys: %s", appliedKubeSecret.Name, strings.Join(driftedKeys, ", "))
+	}
+
+	if checksum := secretDataChecksum(refreshedKubeSecret.Data); checksum != checksumBefore {
+		encryptedSopsSecret.Status.PendingRolloutChecksum = checksum
+	}
+	return r.rolloutPendingWorkloads(ctx, req, encryptedSopsSecret, refreshedKubeSecret)
+}
+
+// applyChildSecret server-side applies a child secret over the live one, and
+// removes what the apply leaves behind, see kubeSecretCleanupPatch. A secret
+// whose type changed is deleted and created anew, as the type is immutable.
+// It returns the secret read back from the apiserver, and whether it had to
+// be refreshed at all.
+func applyChildSecret(
+	ctx context.Context,
+	c client.Client,
+	logger logr.Logger,
+	kubeSecretInCluster *corev1.Secret,
+	appliedKubeSecret *corev1.Secret,
+	replace bool,
+	takeOwnership bool,
+) (*corev1.Secret, bool, error) {
+	needsApply := kubeSecretNeedsApply(kubeSecretInCluster, appliedKubeSecret, replace)
+	cleanupPatch := kubeSecretCleanupPatch(kubeSecretInCluster, appliedKubeSecret, replace, takeOwnership)
+	if !needsApply && cleanupPatch == nil {
+		return kubeSecretInCluster, false, nil
+	}
+
+	logger.Info(
+		"Secret already exists and needs to be refreshed",
+		"secret", appliedKubeSecret.Name,
+		"namespace", appliedKubeSecret.Namespace,
+	)
+	if replace && appliedKubeSecret.Type != kubeSecretInCluster.Type {
+		logger.Info(
+			"Secret type changed, recreating the secret",
+			"secret", appliedKubeSecret.Name,
+			"namespace", appliedKubeSecret.Namespace,
+			"type", appliedKubeSecret.Type,
+		)
+		if err := c.Delete(ctx, kubeSecretInCluster, client.Preconditions{UID: &kubeSecretInCluster.UID}); err != nil {
+			return nil, false, err
+		}
+		cleanupPatch = nil
+	}
+	if needsApply {
+		if err := c.Patch(ctx, appliedKubeSecret, client.Apply, client.FieldOwner(sopsSecretFieldManager), client.ForceOwnership); err != nil {
+			return nil, false, err
+		}
+	}
+	// both patches read back the resulting secret, the cleanup one last
+	refreshedKubeSecret := appliedKubeSecret
+	if cleanupPatch != nil {
+		if err := c.Patch(ctx, kubeSecretInCluster, client.RawPatch(types.MergePatchType, cleanupPatch), client.FieldOwner(sopsSecretFieldManager)); err != nil {
+			return nil, false, err
 		}
+		refreshedKubeSecret = kubeSecretInCluster
+	}
+	logger.Info(
+		"Secret successfully refreshed",
+		"secret", appliedKubeSecret.Name,
+		"namespace", appliedKubeSecret.Namespace,
+	)
+	return refreshedKubeSecret, true, nil
+}
+
+// rolloutPendingWorkloads rolls out the workloads using the child secret after
+// a change recorded in status.pendingRolloutChecksum. The data hash is already
+// recorded by then, so the checksum is kept, and a failure retried as a
+// transient error, until the rollout succeeds.
+func (r *SopsSecretReconciler) rolloutPendingWorkloads(
+	ctx context.Context,
+	req ctrl.Request,
+	encryptedSopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret,
+	kubeSecret *corev1.Secret,
+) error {
+	if encryptedSopsSecret.Status.PendingRolloutChecksum == "" {
+		return nil
+	}
+	// the secret may have changed again since the rollout failed
+	checksum := secretDataChecksum(kubeSecret.Data)
+	if err := r.rolloutWorkloads(ctx, encryptedSopsSecret, kubeSecret.Name, checksum); err != nil {
+		encryptedSopsSecret.Status.Health = lang.SopsUnHealthyStatus
+		encryptedSopsSecret.Status.Message = lang.ErrSopsSecretRolloutFailed
+		encryptedSopsSecret.Status.PendingRolloutChecksum = checksum
+		_ = r.Status().Update(context.Background(), encryptedSopsSecret)
+
 		r.Log.Info(
-			"Secret successfully refreshed",
-			"secret", copyOfKubeSecretInCluster.Name,
-			"namespace", copyOfKubeSecretInCluster.Namespace,
+			"Rollout of workloads using the child secret error",
+			"sopssecret", req.NamespacedName,
+			"error", err,
 		)
+		return err
+	}
+	encryptedSopsSecret.Status.PendingRolloutChecksum = ""
+	return nil
+}
+
+// newAppliedKubeSecret builds the server-side apply configuration of the
+// child secret. Keys are applied through Data, as StringData is write only
+// and would never compare equal with the live secret. The type is left out
+// when merging, as it can't be changed on a shared secret anyway.
+func newAppliedKubeSecret(kubeSecretFromTemplate *corev1.Secret, replace bool) *corev1.Secret {
+	appliedKubeSecret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            kubeSecretFromTemplate.Name,
+			Namespace:       kubeSecretFromTemplate.Namespace,
+			Labels:          kubeSecretFromTemplate.Labels,
+			Annotations:     kubeSecretFromTemplate.Annotations,
+			OwnerReferences: kubeSecretFromTemplate.OwnerReferences,
+		},
+		Data: map[string][]byte{},
+	}
+	for key, value := range kubeSecretFromTemplate.StringData {
+		appliedKubeSecret.Data[key] = []byte(value)
+	}
+	if replace {
+		appliedKubeSecret.Type = kubeSecretFromTemplate.Type
+	}
+	removeUnwantedAnnotations(appliedKubeSecret)
+	return appliedKubeSecret
+}
+
+// kubeSecretNeedsApply diffs the applied configuration against the live
+// secret, so that unchanged secrets are not written on every reconcile
+func kubeSecretNeedsApply(kubeSecretInCluster *corev1.Secret, appliedKubeSecret *corev1.Secret, replace bool) bool {
+	for key, value := range appliedKubeSecret.Data {
+		liveValue, found := kubeSecretInCluster.Data[key]
+		if !found || !bytes.Equal(liveValue, value) {
+			return true
+		}
+	}
+	if !isSubsetOf(appliedKubeSecret.Labels, kubeSecretInCluster.Labels) ||
+		!isSubsetOf(appliedKubeSecret.Annotations, kubeSecretInCluster.Annotations) {
+		return true
+	}
+	if replace && appliedKubeSecret.Type != kubeSecretInCluster.Type {
+		return true
+	}
+	for _, ownerReference := range appliedKubeSecret.OwnerReferences {
+		if !hasOwnerReference(kubeSecretInCluster, ownerReference) {
+			return true
+		}
+	}
+	return false
+}
+
+// kubeSecretCleanupPatch returns a merge patch removing what server-side apply
+// leaves behind: every foreign key, label and annotation when replacing, or
+// the ones written by this operator which are gone from the SopsSecret when
+// merging. It returns nil when there is nothing to remove.
+func kubeSecretCleanupPatch(
+	kubeSecretInCluster *corev1.Secret,
+	appliedKubeSecret *corev1.Secret,
+	replace bool,
+	takeOwnership bool,
+) []byte {
+	staleData := map[string]interface{}{}
+	for _, key := range staleDataKeys(kubeSecretInCluster, appliedKubeSecret, replace) {
+		staleData[key] = nil
+	}
+	staleMetadata := map[string]interface{}{}
+	for field, fields := range map[string][2]map[string]string{
+		"labels":      {kubeSecretInCluster.Labels, appliedKubeSecret.Labels},
+		"annotations": {kubeSecretInCluster.Annotations, appliedKubeSecret.Annotations},
+	} {
+		stale := map[string]interface{}{}
+		for key := range fields[0] {
+			if _, found := fields[1][key]; !found && (replace || isManagedByOperator(kubeSecretInCluster, "f:metadata", "f:"+field, "f:"+key)) {
+				stale[key] = nil
+			}
+		}
+		if len(stale) > 0 {
+			staleMetadata[field] = stale
+		}
+	}
+	if replace && takeOwnership {
+		for _, ownerReference := range kubeSecretInCluster.OwnerReferences {
+			if !hasOwnerReference(appliedKubeSecret, ownerReference) {
+				staleMetadata["ownerReferences"] = appliedKubeSecret.OwnerReferences
+				break
+			}
+		}
+	}
+
+	patch := map[string]interface{}{}
+	if len(staleData) > 0 {
+		patch["data"] = staleData
+	}
+	if len(staleMetadata) > 0 {
+		patch["metadata"] = staleMetadata
+	}
+	if len(patch) == 0 {
+		return nil
+	}
+	patchAsBytes, _ := json.Marshal(patch)
+	return patchAsBytes
+}
+
+// staleDataKeys returns the keys of the live secret to remove: every foreign
+// key when replacing, or the ones written by this operator when merging
+func staleDataKeys(kubeSecretInCluster *corev1.Secret, appliedKubeSecret *corev1.Secret, replace bool) []string {
+	var keys []string
+	for key := range kubeSecretInCluster.Data {
+		if _, found := appliedKubeSecret.Data[key]; !found && (replace || isManagedByOperator(kubeSecretInCluster, "f:data", "f:"+key)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// driftedDataKeys returns the names of the keys whose live value differs from
+// the applied one, or which shouldn't be there at all
+func driftedDataKeys(kubeSecretInCluster *corev1.Secret, appliedKubeSecret *corev1.Secret, replace bool) []string {
+	keys := staleDataKeys(kubeSecretInCluster, appliedKubeSecret, replace)
+	for key, value := range appliedKubeSecret.Data {
+		if liveValue, found := kubeSecretInCluster.Data[key]; !found || !bytes.Equal(liveValue, value) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isManagedByOperator looks up a field path in the managed fields written
+// by sopsSecretFieldManager, whichever the operation
+func isManagedByOperator(secret *corev1.Secret, fieldPath ...string) bool {
+	for _, managedField := range secret.ManagedFields {
+		if managedField.Manager != sopsSecretFieldManager || managedField.FieldsV1 == nil {
+			continue
+		}
+		var fields map[string]interface{}
+		if err := json.Unmarshal(managedField.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		found := true
+		for _, field := range fieldPath {
+			child, ok := fields[field].(map[string]interface{})
+			if !ok {
+				found = false
+				break
+			}
+			fields = child
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+func isSubsetOf(subset map[string]string, superset map[string]string) bool {
+	for key, value := range subset {
+		if supersetValue, found := superset[key]; !found || supersetValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// isChildOf checks whether the SopsSecret controls the secret, or merges into
+// a secret controlled by another object, see yieldToLiveController
+func isChildOf(sopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret, secret *corev1.Secret) bool {
+	if metav1.IsControlledBy(secret, sopsSecret) {
+		return true
+	}
+	return sopsSecret.Spec.MergeStrategy == gitopssecretsnappcloudiov1alpha1.MergeStrategyMerge &&
+		hasOwnerReference(secret, metav1.OwnerReference{UID: sopsSecret.UID})
+}
+
+// yieldToLiveController turns the applied owner references into plain ones
+// when the live secret is controlled by another object, as the apiserver
+// rejects a second controller. Merging keeps the secret of the other object,
+// which is only garbage collected once both owners are gone.
+func yieldToLiveController(appliedKubeSecret *corev1.Secret, kubeSecretInCluster *corev1.Secret) {
+	controller := metav1.GetControllerOf(kubeSecretInCluster)
+	if controller == nil || hasOwnerReference(appliedKubeSecret, *controller) {
+		return
+	}
+	ownerReferences := make([]metav1.OwnerReference, 0, len(appliedKubeSecret.OwnerReferences))
+	for _, ownerReference := range appliedKubeSecret.OwnerReferences {
+		ownerReference.Controller = nil
+		ownerReferences = append(ownerReferences, ownerReference)
+	}
+	appliedKubeSecret.OwnerReferences = ownerReferences
+}
+
+func hasOwnerReference(object metav1.Object, ownerReference metav1.OwnerReference) bool {
+	for _, existing := range object.GetOwnerReferences() {
+		if existing.UID == ownerReference.UID {
+			return true
+		}
 	}
 	return false
 }
@@ -239,7 +838,7 @@ func (r *SopsSecretReconciler) getSecretFromClusterOrCreateFromTemplate(
 	req ctrl.Request,
 	encryptedSopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret,
 	kubeSecretFromTemplate *corev1.Secret,
-) (*corev1.Secret, bool) {
+) (*corev1.Secret, error) {
 
 	// Check if kubeSecretFromTemplate already exists in the cluster store
 	kubeSecretToFindAndCompare := &corev1.Secret{}
@@ -259,8 +858,8 @@ func (r *SopsSecretReconciler) getSecretFromClusterOrCreateFromTemplate(
 			"sopssecret", req.NamespacedName,
 			"message", err,
 		)
-		err = r.Create(ctx, kubeSecretFromTemplate)
-		kubeSecretToFindAndCompare = kubeSecretFromTemplate.DeepCopy()
+		kubeSecretToFindAndCompare = newAppliedKubeSecret(kubeSecretFromTemplate, true)
+		err = r.Create(ctx, kubeSecretToFindAndCompare, client.FieldOwner(sopsSecretFieldManager))
 	}
 
 	// Unknown error while trying to find kubeSecretFromTemplate in cluster - reschedule reconciliation
@@ -274,10 +873,10 @@ func (r *SopsSecretReconciler) getSecretFromClusterOrCreateFromTemplate(
 			"sopssecret", req.NamespacedName,
 			"error", err,
 		)
-		return nil, true
+		return nil, err
 	}
 
-	return kubeSecretToFindAndCompare, false
+	return kubeSecretToFindAndCompare, nil
 }
 
 func (r *SopsSecretReconciler) newKubeSecretFromTemplate(
@@ -285,7 +884,7 @@ func (r *SopsSecretReconciler) newKubeSecretFromTemplate(
 	encryptedSopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret,
 	plainTextSopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret,
 	stringData *map[string]string,
-) (*corev1.Secret, bool) {
+) (*corev1.Secret, error) {
 
 	// Define a new secret object
 	kubeSecretFromTemplate, err := createKubeSecretFromTemplate(plainTextSopsSecret, stringData, r.Log)
@@ -299,7 +898,7 @@ func (r *SopsSecretReconciler) newKubeSecretFromTemplate(
 			"sopssecret", req.NamespacedName,
 			"error", err,
 		)
-		return nil, true
+		return nil, err
 	}
 
 	// Set encryptedSopsSecret as the owner of kubeSecret
@@ -315,10 +914,10 @@ func (r *SopsSecretReconciler) newKubeSecretFromTemplate(
 			"error", err,
 		)
 
-		return nil, true
+		return nil, err
 	}
 
-	return kubeSecretFromTemplate, false
+	return kubeSecretFromTemplate, nil
 }
 
 func (r *SopsSecretReconciler) isSecretSuspended(
@@ -371,11 +970,6 @@ func (r *SopsSecretReconciler) getEncryptedSopsSecret(
 	return encryptedSopsSecret, false, nil
 }
 
-// checks if the annotation equals to "true", and it's case sensitive
-func isAnnotatedToBeManaged(secret *corev1.Secret) bool {
-	return secret.Annotations[gitopssecretsnappcloudiov1alpha1.SopsSecretManagedAnnotation] == "true"
-}
-
 // SetupWithManager sets up the controller with the Manager.
 func (r *SopsSecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
@@ -388,8 +982,13 @@ func (r *SopsSecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	}
 
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&gitopssecretsnappcloudiov1alpha1.SopsSecret{}).
+		For(&gitopssecretsnappcloudiov1alpha1.SopsSecret{}, builder.WithPredicates(specChangedPredicate)).
 		Owns(&corev1.Secret{}).
+		Watches(&gitopssecretsnappcloudiov1alpha1.GPGKey{}, gpgKeyReferrersHandler(r.Client, r.Log, func() client.ObjectList {
+			return &gitopssecretsnappcloudiov1alpha1.SopsSecretList{}
+		})).
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.sopsSecretsReferencingSource)).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.sopsSecretsReferencingSource)).
 		Complete(r)
 }
 
@@ -399,10 +998,17 @@ func createKubeSecretFromTemplate(
 	stringData *map[string]string,
 	logger logr.Logger,
 ) (*corev1.Secret, error) {
-	kubeSecretType := "Opaque"
+	kubeSecretType := sopsSecret.Spec.SecretType()
 	labels := cloneMap(sopsSecret.Labels)
 	annotations := cloneMap(sopsSecret.Annotations)
 
+	data := make(map[string][]byte, len(*stringData))
+	for key, value := range *stringData {
+		data[key] = []byte(value)
+	}
+	annotations[gitopssecretsnappcloudiov1alpha1.SopsSecretDataHashAnnotation] = secretDataChecksum(data)
+	annotations[gitopssecretsnappcloudiov1alpha1.SopsSecretOwnerUIDAnnotation] = string(sopsSecret.UID)
+
 	logger.Info("Processing",
 		"sopssecret", fmt.Sprintf("%s.%s.%s", sopsSecret.Kind, sopsSecret.APIVersion, sopsSecret.Name),
 		"type", kubeSecretType,
@@ -433,11 +1039,63 @@ func cloneMap(oldMap map[string]string) map[string]string {
 	return newMap
 }
 
-// decryptSopsSecretInstance decrypts spec.secretTemplates
+// decryptSopsSecretInstance decrypts the SopsSecret, its document and the
+// source file of its sourceRef. A SopsSecret only holding a document or a
+// sourceRef needs no encryption of its own.
 func decryptSopsSecretInstance(
 	encryptedSopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret,
+	source []byte,
 	logger logr.Logger,
-	passphrase string,
+	passphrases ...string,
+) (*gitopssecretsnappcloudiov1alpha1.SopsSecret, []byte, error) {
+	spec := encryptedSopsSecret.Spec
+	decryptedSopsSecret := encryptedSopsSecret.DeepCopy()
+	if encryptedSopsSecret.Sops.Encrypted() || (spec.Document == nil && spec.SourceRef == nil) ||
+		len(spec.StringData) > 0 || len(spec.Files) > 0 || len(spec.HelperKeys()) > 0 {
+		var err error
+		decryptedSopsSecret, err = decryptSopsSecretFields(encryptedSopsSecret, logger, passphrases...)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	verifyMAC := encryptedSopsSecret.GetAnnotations()[gitopssecretsnappcloudiov1alpha1.SopsSecretSkipMACVerificationAnnotation] != "true"
+
+	if document := decryptedSopsSecret.Spec.Document; document != nil {
+		cleartext, err := decryptSopsDocument(document.Format, []byte(document.Data), verifyMAC, passphrases...)
+		if err != nil {
+			logger.Info(
+				"Failed to Decrypt the document of sops secret decryptedSopsSecret",
+				"sopssecret", fmt.Sprintf("%s/%s", encryptedSopsSecret.Namespace, encryptedSopsSecret.Name),
+				"error", err,
+			)
+			return nil, nil, err
+		}
+		document.Data = string(cleartext)
+	}
+
+	var sourceCleartext []byte
+	if sourceRef := decryptedSopsSecret.Spec.SourceRef; sourceRef != nil {
+		var err error
+		sourceCleartext, err = decryptSopsDocument(sourceRef.Format, source, verifyMAC, passphrases...)
+		if err != nil {
+			logger.Info(
+				"Failed to Decrypt the sourceRef of sops secret decryptedSopsSecret",
+				"sopssecret", fmt.Sprintf("%s/%s", encryptedSopsSecret.Namespace, encryptedSopsSecret.Name),
+				"error", err,
+			)
+			return nil, nil, err
+		}
+	}
+
+	return decryptedSopsSecret, sourceCleartext, nil
+}
+
+// decryptSopsSecretFields decrypts spec.secretTemplates, the sops metadata
+// is marshaled as stored so every field written by sops is honored
+func decryptSopsSecretFields(
+	encryptedSopsSecret *gitopssecretsnappcloudiov1alpha1.SopsSecret,
+	logger logr.Logger,
+	passphrases ...string,
 ) (*gitopssecretsnappcloudiov1alpha1.SopsSecret, error) {
 	sopsSecretAsBytes, err := json.Marshal(encryptedSopsSecret)
 	if err != nil {
@@ -449,7 +1107,15 @@ func decryptSopsSecretInstance(
 		return nil, err
 	}
 
-	decryptedSopsSecretAsBytes, err := customDecryptData(sopsSecretAsBytes, "json", passphrase)
+	macOnlyEncrypted := sopsMACOnlyEncrypted("json", sopsSecretAsBytes)
+	if macOnlyEncrypted {
+		// the encrypted values are hashed in the order the apiserver keeps them
+		sopsSecretAsBytes, err = sortedJSON(sopsSecretAsBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	decryptedSopsSecretAsBytes, mac, treeMAC, err := customDecryptData(sopsSecretAsBytes, "json", macOnlyEncrypted, passphrases...)
 	if err != nil {
 		logger.Info(
 			"Failed to Decrypt encrypted sops secret decryptedSopsSecret",
@@ -470,52 +1136,96 @@ func decryptSopsSecretInstance(
 		return nil, err
 	}
 
+	// sops hashes every value of the document unless mac_only_encrypted is
+	// set, and the apiserver rewrites the metadata of the CR, so the mac can
+	// only be reproduced for the encrypted values. Anything else is refused
+	// unless the SopsSecret opts out of the verification.
+	switch {
+	case encryptedSopsSecret.GetAnnotations()[gitopssecretsnappcloudiov1alpha1.SopsSecretSkipMACVerificationAnnotation] == "true":
+	case !macOnlyEncrypted:
+		logger.Info(
+			"Failed to verify the sops mac of sops secret, it wasn't encrypted with mac_only_encrypted",
+			"sopssecret", fmt.Sprintf("%s/%s", encryptedSopsSecret.Namespace, encryptedSopsSecret.Name),
+		)
+		return nil, errSopsSecretMACUnverifiable
+	case treeMAC != mac:
+		logger.Info(
+			"Failed to verify the sops mac of decrypted sops secret decryptedSopsSecret",
+			"sopssecret", fmt.Sprintf("%s/%s", encryptedSopsSecret.Namespace, encryptedSopsSecret.Name),
+		)
+		return nil, errSopsSecretMACMismatch
+	}
+
 	return decryptedSopsSecret, nil
 }
 
 // Data is a helper that takes encrypted data and a format string,
 // decrypts the data and returns its cleartext in an []byte.
-// The format string can be `json`, `yaml`, `dotenv` or `binary`.
+// The format string can be `json`, `yaml`, `dotenv`, `ini` or `binary`.
 // If the format string is empty, binary format is assumed.
 // NOTE: this function is taken from sops code and adjusted
-//       to ignore mac, as CR will always be mutated in k8s
-func customDecryptData(data []byte, format string, passphrase string) (cleartext []byte, err error) {
-	// Initialize a Sops JSON store
-	var store sops.Store
-
-	switch format {
-	case "json":
-		store = &sopsjson.Store{}
-	case "yaml":
-		store = &sopsyaml.Store{}
-	case "dotenv":
-		store = &sopsdotenv.Store{}
-	default:
-		store = &sopsjson.BinaryStore{}
-	}
+//       to return the decrypted mac and the mac of the decrypted tree
+//       instead of verifying them, as the CR will always be mutated in
+//       k8s. With macOnlyEncrypted the mac of the tree only covers its
+//       encrypted values, as sops does with mac_only_encrypted.
+func customDecryptData(data []byte, format string, macOnlyEncrypted bool, passphrases ...string) (cleartext []byte, mac string, treeMAC string, err error) {
+	store := sopsStoreForFormat(format)
 
 	// Load SOPS file and access the data key
 	tree, err := store.LoadEncryptedFile(data)
 	if err != nil {
-		return nil, err
+		return nil, "", "", err
 	}
 
-	key, err := GetDataKeyCustom(tree.Metadata, passphrase)
+	key, err := GetDataKeyCustom(tree.Metadata, passphrases...)
 	if userErr, ok := err.(sops.UserError); ok {
 		err = fmt.Errorf(userErr.UserError())
 	}
 	if err != nil {
-		return nil, err
+		return nil, "", "", err
 	}
 
 	// Decrypt the tree
 	cipher := sopsaes.NewCipher()
-	_, err = tree.Decrypt(key, cipher)
+	encryptedValuesCipher := &macOnlyEncryptedCipher{Cipher: cipher, hash: sha512.New()}
+	treeMAC, err = tree.Decrypt(key, encryptedValuesCipher)
 	if err != nil {
-		return nil, err
+		return nil, "", "", err
+	}
+	if macOnlyEncrypted {
+		treeMAC = fmt.Sprintf("%X", encryptedValuesCipher.hash.Sum(nil))
+	}
+
+	// bytes values are emitted as base64 by the json store, keep them as text
+	for _, branch := range tree.Branches {
+		bytesToStrings(branch)
+	}
+
+	// an undecryptable mac is left empty, it fails verification unless skipped
+	decryptedMac, err := cipher.Decrypt(tree.Metadata.MessageAuthenticationCode, key, tree.Metadata.LastModified.Format(time.RFC3339))
+	if err == nil {
+		mac, _ = decryptedMac.(string)
 	}
 
-	return store.EmitPlainFile(tree.Branches)
+	cleartext, err = store.EmitPlainFile(tree.Branches)
+	return cleartext, mac, treeMAC, err
+}
+
+// bytesToStrings replaces the []byte leaves of a decrypted sops value with strings
+func bytesToStrings(in interface{}) interface{} {
+	switch value := in.(type) {
+	case []byte:
+		return string(value)
+	case sops.TreeBranch:
+		for i := range value {
+			value[i].Value = bytesToStrings(value[i].Value)
+		}
+	case []interface{}:
+		for i := range value {
+			value[i] = bytesToStrings(value[i])
+		}
+	}
+	return in
 }
 
 func removeUnwantedAnnotations(secret *corev1.Secret) {
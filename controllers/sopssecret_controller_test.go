@@ -50,6 +50,8 @@ var _ = Describe("", func() {
 		obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(content, nil, nil)
 		TestSopsSecretObj = obj.(*gitopssecretsnappcloudiov1alpha1.SopsSecret)
 		Expect(err).Should(BeNil())
+		// example.enc.yaml was encrypted without mac_only_encrypted
+		TestSopsSecretObj.Annotations[gitopssecretsnappcloudiov1alpha1.SopsSecretSkipMACVerificationAnnotation] = "true"
 	})
 
 	const (
@@ -118,9 +120,14 @@ var _ = Describe("", func() {
 			Expect(len(testSecret.GetLabels())).NotTo(Equal(0))
 			Expect(testSecret.GetLabels()).Should(Equal(TestSopsSecretObj.GetLabels()))
 
-			// test annotations are equal with parent
+			// test annotations are equal with parent, plus the data hash and owner uid
 			Expect(len(testSecret.GetAnnotations())).NotTo(Equal(0))
-			Expect(testSecret.GetAnnotations()).Should(Equal(TestSopsSecretObj.GetAnnotations()))
+			for key, value := range TestSopsSecretObj.GetAnnotations() {
+				Expect(testSecret.GetAnnotations()).Should(HaveKeyWithValue(key, value))
+			}
+			Expect(testSecret.GetAnnotations()).Should(HaveKey(gitopssecretsnappcloudiov1alpha1.SopsSecretDataHashAnnotation))
+			Expect(testSecret.GetAnnotations()).Should(HaveKey(gitopssecretsnappcloudiov1alpha1.SopsSecretOwnerUIDAnnotation))
+			Expect(len(testSecret.GetAnnotations())).Should(Equal(len(TestSopsSecretObj.GetAnnotations()) + 2))
 
 			Expect(controller.K8sClient.Delete(ctx, testSecret)).To(Succeed())
 			time.Sleep(10 * time.Second)
@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+	"github.com/snapp-incubator/sops-operator/lang"
+)
+
+var _ = Describe("Expired GPGKeys of healthy SopsSecrets", func() {
+	newGPGKey := func(expiresAt time.Time) *gitopssecretsnappcloudiov1alpha1.GPGKey {
+		expiry := metav1.NewTime(expiresAt)
+		return &gitopssecretsnappcloudiov1alpha1.GPGKey{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example-gpgkey"},
+			Status:     gitopssecretsnappcloudiov1alpha1.GPGKeyStatus{ExpiresAt: &expiry},
+		}
+	}
+
+	It("Should warn when an expired GPGKey decrypted the SopsSecret", func() {
+		recorder := record.NewFakeRecorder(1)
+		reconciler := &SopsSecretReconciler{Log: ctrl.Log, Recorder: recorder}
+		sopsSecret := &gitopssecretsnappcloudiov1alpha1.SopsSecret{}
+
+		reconciler.warnExpiredGPGKeys(sopsSecret, newGPGKey(time.Now().Add(-time.Hour)))
+		Expect(sopsSecret.Status.Message).To(Equal(lang.WarnGPGKeyExpired))
+		Expect(<-recorder.Events).To(ContainSubstring("default/example-gpgkey"))
+
+		reconciler.warnExpiredGPGKeys(sopsSecret, newGPGKey(time.Now().Add(time.Hour)))
+		Expect(sopsSecret.Status.Message).To(BeEmpty())
+		Expect(recorder.Events).To(BeEmpty())
+	})
+})
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/yaml"
+
+	gitopssecretsnappcloudiov1alpha1 "github.com/snapp-incubator/sops-operator/api/v1alpha1"
+)
+
+var _ = Describe("SopsSecret mac verification", func() {
+	// example.enc.yaml was encrypted by the sops cli, its mac covers every value
+	readEncryptedExample := func() ([]byte, string) {
+		gpgKeyContent, err := ioutil.ReadFile(filepath.Join("..", "config", "pgp-test-key", "gpgkey.yaml"))
+		Expect(err).To(BeNil())
+		gpgKey := &gitopssecretsnappcloudiov1alpha1.GPGKey{}
+		Expect(yaml.Unmarshal(gpgKeyContent, gpgKey)).To(Succeed())
+		keyDirPath, err := ioutil.TempDir("", "gpgkey")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(keyDirPath)
+		keyFilePath := filepath.Join(keyDirPath, "gpgkey-sample")
+		Expect(createKeyFile(keyFilePath, gpgKey.Spec.ArmoredPrivateKey)).To(Succeed())
+		Expect(exec.Command(gpgBinary(), "--batch", "--import", keyFilePath).Run()).To(Succeed())
+
+		content, err := ioutil.ReadFile(filepath.Join("..", "config", "pgp-test-key", "example.enc.yaml"))
+		Expect(err).To(BeNil())
+		return content, gpgKey.Spec.Passphrase
+	}
+
+	It("Should verify the mac of a document encrypted by sops", func() {
+		content, passphrase := readEncryptedExample()
+		_, err := decryptSopsDocument("yaml", content, true, passphrase)
+		Expect(err).To(BeNil())
+	})
+
+	It("Should fail a document missing one of its values", func() {
+		content, passphrase := readEncryptedExample()
+		lines := bytes.Split(content, []byte("\n"))
+		var tampered [][]byte
+		for _, line := range lines {
+			if !bytes.Contains(line, []byte("data-name1:")) {
+				tampered = append(tampered, line)
+			}
+		}
+		_, err := decryptSopsDocument("yaml", bytes.Join(tampered, []byte("\n")), true, passphrase)
+		Expect(err).To(Equal(errSopsSecretMACMismatch))
+	})
+
+	It("Should hash only the encrypted values with mac_only_encrypted", func() {
+		content, passphrase := readEncryptedExample()
+		_, mac, treeMAC, err := customDecryptData(content, "yaml", false, passphrase)
+		Expect(err).To(BeNil())
+		Expect(treeMAC).To(Equal(mac))
+
+		_, _, treeMAC, err = customDecryptData(content, "yaml", true, passphrase)
+		Expect(err).To(BeNil())
+		Expect(treeMAC).To(Equal(fmt.Sprintf("%X", sha512.Sum512([]byte("data-value0data-value1")))))
+	})
+
+	It("Should refuse a SopsSecret whose mac can't be reproduced", func() {
+		content, passphrase := readEncryptedExample()
+		sopsSecret := &gitopssecretsnappcloudiov1alpha1.SopsSecret{}
+		Expect(yaml.Unmarshal(content, sopsSecret)).To(Succeed())
+		sopsSecret.ResourceVersion = "1"
+
+		_, err := decryptSopsSecretFields(sopsSecret, ctrl.Log, passphrase)
+		Expect(err).To(Equal(errSopsSecretMACUnverifiable))
+	})
+
+	It("Should decrypt a SopsSecret whose mac can't be reproduced when it skips the verification", func() {
+		content, passphrase := readEncryptedExample()
+		sopsSecret := &gitopssecretsnappcloudiov1alpha1.SopsSecret{}
+		Expect(yaml.Unmarshal(content, sopsSecret)).To(Succeed())
+		sopsSecret.ResourceVersion = "1"
+		sopsSecret.SetAnnotations(map[string]string{
+			gitopssecretsnappcloudiov1alpha1.SopsSecretSkipMACVerificationAnnotation: "true",
+		})
+
+		decryptedSopsSecret, err := decryptSopsSecretFields(sopsSecret, ctrl.Log, passphrase)
+		Expect(err).To(BeNil())
+		Expect(string(decryptedSopsSecret.Spec.StringData["data-name0"].Raw)).To(Equal(`"data-value0"`))
+	})
+
+	It("Should read mac_only_encrypted from the metadata of every format", func() {
+		Expect(sopsMACOnlyEncrypted("yaml", []byte("a: b\nsops:\n    mac_only_encrypted: true\n"))).To(BeTrue())
+		Expect(sopsMACOnlyEncrypted("json", []byte(`{"a":"b","sops":{"mac_only_encrypted":true}}`))).To(BeTrue())
+		Expect(sopsMACOnlyEncrypted("dotenv", []byte("a=b\nsops_mac_only_encrypted=true\n"))).To(BeTrue())
+		Expect(sopsMACOnlyEncrypted("ini", []byte("[a]\nb = c\n[sops]\nmac_only_encrypted = true\n"))).To(BeTrue())
+		Expect(sopsMACOnlyEncrypted("ini", []byte("[a]\nmac_only_encrypted = true\n"))).To(BeFalse())
+		Expect(sopsMACOnlyEncrypted("yaml", []byte("a: b\nsops:\n    version: 3.7.2\n"))).To(BeFalse())
+	})
+})
@@ -2,11 +2,38 @@ package lang
 
 // api variables
 var (
-	// ErrSopsSecretSpecGPGKeyRefNameEmpty when SopsSecret object's Spec.GPGKeyRefName is empty
-	ErrSopsSecretSpecGPGKeyRefNameEmpty = "gpg_key_ref_name can't be empty in SopsSecret object"
+	// ErrSopsSecretSpecGPGKeyRefNameEmpty when SopsSecret object has neither Spec.GPGKeyRefName nor Spec.GPGKeyRefs
+	ErrSopsSecretSpecGPGKeyRefNameEmpty = "gpg_key_ref_name or gpgKeyRefs can't be empty in SopsSecret object"
 
-	// ErrSopsSecretSpecNoData when SopsSecret object's Spec.SecretTemplate.Name is empty
-	ErrSopsSecretSpecNoData = "stringData can't be empty in SopsSecret object"
+	// ErrSopsSecretSpecGPGKeyRefNotGranted when a GPGKey in another namespace is referenced without a GPGKeyGrant
+	ErrSopsSecretSpecGPGKeyRefNotGranted = "gpgKeyRefs references a GPGKey in another namespace which no GPGKeyGrant permits"
+
+	// ErrSopsSecretSpecGPGKeyRefNotAllowed when a referenced GPGKey doesn't allow the SopsSecret by its allowedSecrets
+	ErrSopsSecretSpecGPGKeyRefNotAllowed = "gpgKeyRefs references a GPGKey whose allowedSecrets don't match this SopsSecret"
+
+	// ErrSopsSecretSpecNoData when SopsSecret object has neither Spec.StringData, Spec.Files, Spec.Document, Spec.SourceRef nor a typed helper
+	ErrSopsSecretSpecNoData = "stringData, files, document, sourceRef and the typed helpers can't all be empty in SopsSecret object"
+
+	// ErrSopsSecretSpecHelpersInvalid when more than one typed helper is set, or one conflicts with Spec.Type or another key
+	ErrSopsSecretSpecHelpersInvalid = "only one of dockerConfig, tls and basicAuth can be set, matching type and not overlapping stringData or files"
+
+	// ErrSopsSecretSpecTypeKeysMissing when the keys required by the built-in Secret type are not set
+	ErrSopsSecretSpecTypeKeysMissing = "stringData, files and the typed helpers must set every key required by the secret type"
+
+	// ErrSopsSecretSpecSourceRefInvalid when sourceRef doesn't set exactly one source or can't be written to the child secret
+	ErrSopsSecretSpecSourceRefInvalid = "sourceRef must set exactly one of configMap, secret and oci, and a valid key for binary files"
+
+	// ErrSopsSecretSpecFilesInvalid when a file isn't a map, has an invalid name or collides with a stringData key
+	ErrSopsSecretSpecFilesInvalid = "files must be maps named by valid secret keys which are not in stringData"
+
+	// ErrSopsSecretSpecStringDataStructured when a stringData value is a map or a list
+	ErrSopsSecretSpecStringDataStructured = "stringData values must be strings, numbers or booleans, maps and lists can't be stored in a Secret"
+
+	// ErrSopsFileSpecTargetInvalid when target.name isn't a valid secret name or target.key a valid secret key
+	ErrSopsFileSpecTargetInvalid = "target.name must be a valid secret name and target.key a valid secret key"
+
+	// ErrSopsFileSpecExplodeBinary when a binary SopsFile is exploded, as it has no keys of its own
+	ErrSopsFileSpecExplodeBinary = "binary files can't be exploded, their whole content is written to target.key"
 
 	// ErrGPGKeySpecPassphraseLength when length of the provided password is not enough
 	ErrGPGKeySpecPassphraseLength = "passphrase length should be greater equal to 14 and lower equal to 100"
@@ -19,19 +46,112 @@ var (
 
 	// ErrGPGKeySpecArmoredPrivateKeyPrefixSuffix when key string has the pgp key prefix or suffix
 	ErrGPGKeySpecArmoredPrivateKeyPrefixSuffix = "object GPGKey on field ArmoredPrivateKey should not have prefix or suffix of dashes"
+
+	// ErrGPGKeySpecGenerateWithKey when a generated GPGKey also sets the private key or passphrase
+	ErrGPGKeySpecGenerateWithKey = "armored_private_key and passphrase must be empty when generate is set"
+
+	// ErrGPGKeySpecAllowedSecretsInvalid when the selector or name pattern of allowedSecrets can't be parsed
+	ErrGPGKeySpecAllowedSecretsInvalid = "allowedSecrets has an invalid selector or namePattern"
+
+	// ErrGPGKeySpecGenerateUID when the uid of a generated GPGKey is empty or spans multiple lines
+	ErrGPGKeySpecGenerateUID = "generate.uid can't be empty and must be a single line"
+
+	// ErrGPGKeySpecGenerateExpiry when the expiry of a generated GPGKey is not in a gpg Expire-Date format
+	ErrGPGKeySpecGenerateExpiry = "generate.expiry must be 0, a number of days optionally suffixed by d, w, m or y, or a yyyy-mm-dd date"
 )
 
 // controller variables
 var (
-	// ErrGPGKeyRefFetchFail when fails to fetch GPGKey object by name specified in SopsSecret.Spec.gpg_key_ref_name
+	// ErrGPGKeyRefFetchFail when fails to fetch every GPGKey object referenced by SopsSecret.Spec
 	ErrGPGKeyRefFetchFail = "Err fetching GPGKeyRefName"
 
+	// ErrGPGKeyRefNotGranted when a referenced GPGKey in another namespace is not permitted by a GPGKeyGrant
+	ErrGPGKeyRefNotGranted = "Referenced GPGKey is not granted to this namespace"
+
+	// ErrGPGKeyRefNotAllowed when the allowedSecrets of every referenced GPGKey exclude the SopsSecret
+	ErrGPGKeyRefNotAllowed = "Referenced GPGKey does not allow this SopsSecret"
+
 	// ErrSopsSecretDecryptionFailed when failed to decrypt SopsSecret object
 	ErrSopsSecretDecryptionFailed = "Decryption error"
 
+	// ErrSopsSecretStringDataStructured when a decrypted stringData value is a map or a list
+	ErrSopsSecretStringDataStructured = "Decrypted stringData has a map or list value"
+
+	// ErrSopsSecretFilesRenderFailed when a decrypted file can't be serialized in the format of its name or is already set
+	ErrSopsSecretFilesRenderFailed = "Rendering files error"
+
+	// ErrSopsSecretDocumentExpandFailed when a decrypted document has an entry which isn't a valid secret key or is already set
+	ErrSopsSecretDocumentExpandFailed = "Expanding document error"
+
+	// ErrSopsSecretSourceFetchFailed when the file of sourceRef can't be fetched
+	ErrSopsSecretSourceFetchFailed = "Fetching sourceRef error"
+
+	// ErrSopsSecretSourceExpandFailed when the decrypted sourceRef can't be written to the child secret
+	ErrSopsSecretSourceExpandFailed = "Expanding sourceRef error"
+
+	// ErrSopsSecretHelpersExpandFailed when the decrypted typed helpers can't be written to the child secret
+	ErrSopsSecretHelpersExpandFailed = "Expanding typed helpers error"
+
+	// ErrSopsSecretTypeKeysMissing when the decrypted SopsSecret lacks a key required by the Secret type
+	ErrSopsSecretTypeKeysMissing = "Keys required by the secret type are missing"
+
+	// ErrSopsFileMACMismatch when the decrypted SopsFile doesn't match its sops mac
+	ErrSopsFileMACMismatch = "MAC verification error, the decrypted file doesn't match its sops mac"
+
+	// ErrSopsFileExplodeFailed when a decrypted SopsFile can't be exploded into secret keys
+	ErrSopsFileExplodeFailed = "Exploding file error"
+
+	// ErrSopsFileTargetNotOwned when the target secret of a SopsFile exists and its adoptionPolicy doesn't let the SopsFile take it over
+	ErrSopsFileTargetNotOwned = "Target secret is not owned by this SopsFile"
+
+	// ErrSopsFileTargetBackupFailed when controller fails to back up an existing target secret before adopting it
+	ErrSopsFileTargetBackupFailed = "Backing up the adopted target secret error"
+
+	// ErrSopsFilePruneFailed when the secret written before target.name changed can't be deleted or orphaned
+	ErrSopsFilePruneFailed = "Removing the previous target secret error"
+
+	// ErrSopsFileCouldNotOrphan when controller fails to release the target secret of a deleted SopsFile
+	ErrSopsFileCouldNotOrphan = "Target secret orphaning error"
+
+	// ErrSopsManifestInvalid when a decrypted SopsManifest isn't a single namespaced object of its namespace
+	ErrSopsManifestInvalid = "Decrypted manifest is not a valid namespaced object"
+
+	// ErrSopsManifestKindNotAllowed when the kind of a decrypted SopsManifest isn't in the allowlist of the operator
+	ErrSopsManifestKindNotAllowed = "Kind of the decrypted manifest is not allowed by the operator"
+
+	// ErrSopsManifestObjectNotOwned when the object of a SopsManifest exists and its adoptionPolicy doesn't allow taking it over
+	ErrSopsManifestObjectNotOwned = "Object of the manifest is not owned by this SopsManifest"
+
+	// ErrSopsManifestApplyFailed when the object of a SopsManifest can't be applied
+	ErrSopsManifestApplyFailed = "Applying the manifest error"
+
+	// ErrSopsManifestBackupFailed when controller fails to back up an existing object before adopting it
+	ErrSopsManifestBackupFailed = "Backing up the adopted object error"
+
+	// ErrSopsManifestPruneFailed when the object applied before the manifest was renamed or changed kind can't be deleted or orphaned
+	ErrSopsManifestPruneFailed = "Removing the previously applied object error"
+
+	// ErrSopsManifestCouldNotOrphan when controller fails to release the object of a deleted SopsManifest
+	ErrSopsManifestCouldNotOrphan = "Applied object orphaning error"
+
+	// ErrSopsSecretMACMismatch when the decrypted files and stringData don't match the sops mac of the SopsSecret
+	ErrSopsSecretMACMismatch = "MAC verification error, files and stringData don't match the sops mac"
+
+	// ErrSopsSecretMACUnverifiable when the sops mac of a SopsSecret encrypted without mac_only_encrypted can't be verified
+	ErrSopsSecretMACUnverifiable = "MAC verification error, encrypt the SopsSecret with mac_only_encrypted or set the skip-mac-verification annotation"
+
+	// ErrGPGKeyPassphraseFetchFail when the passphrase of a generated GPGKey can't be read from its Secret
+	ErrGPGKeyPassphraseFetchFail = "Err fetching passphrase of the GPGKey"
+
+	// ErrGPGKeyExpired when decryption failed and the referenced GPGKey has expired
+	ErrGPGKeyExpired = "Decryption error, referenced GPGKey has expired"
+
 	// ErrSopsSecretChildNotOwned when child is not owned by controller
 	ErrSopsSecretChildNotOwned = "Child secret is not owned by controller error"
 
+	// ErrSopsSecretChildBackupFailed when controller fails to back up an existing secret before adopting it
+	ErrSopsSecretChildBackupFailed = "Backing up the adopted child secret error"
+
 	// ErrSopsSecretCouldNotUpdateChild when controller fails to update child secret
 	ErrSopsSecretCouldNotUpdateChild = "Child secret update error"
 
@@ -44,9 +164,18 @@ var (
 	// ErrSopsSecretChildSecretOwnerShip when controller fails to set ownership of child secret
 	ErrSopsSecretChildSecretOwnerShip = "Setting controller ownership of the child secret error"
 
+	// ErrSopsSecretRolloutFailed when the workloads using the updated child secret couldn't be rolled out
+	ErrSopsSecretRolloutFailed = "Rolling out workloads using the child secret error"
+
+	// ErrSopsSecretCouldNotOrphanChild when controller fails to release the child secret of a deleted SopsSecret
+	ErrSopsSecretCouldNotOrphanChild = "Child secret orphaning error"
+
 	// SopsSecretSuspended when reconciling is ignored due to suspend flag
 	SopsSecretSuspended = "Reconciliation is suspended"
 
+	// WarnGPGKeyExpired when the SopsSecret is healthy but was decrypted with an expired GPGKey
+	WarnGPGKeyExpired = "Decrypted with an expired GPGKey, re-encrypt with a valid GPGKey"
+
 	// SopsHealthyStatus to show sopssecret object is healthy
 	SopsHealthyStatus = "Healthy"
 	// SopsUnHealthyStatus to show sopssecret object is unhealthy
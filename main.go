@@ -19,6 +19,7 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -28,6 +29,7 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
@@ -54,13 +56,22 @@ func main() {
 	var probeAddr string
 	var SopsSecretRequeueAfter int64
 	var GPGKeyRequeueAfter int64
+	var GPGKeyExpiryWarningDays int64
+	var KeyRotationRequeueAfter int64
+	var SopsManifestAllowedKinds string
+	var SyncPeriod time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
-	flag.Int64Var(&GPGKeyRequeueAfter, "gpgkey-requeue-after", 5, "Requeue failed reconciliation on sopsSecret decryption in minutes (min 1).")
-	flag.Int64Var(&SopsSecretRequeueAfter, "sopssecret-requeue-after", 5, "Requeue failed reconciliation on gpgkey imports in minutes (min 1).")
+	flag.Int64Var(&GPGKeyRequeueAfter, "gpgkey-requeue-after", 5, "Requeue gpgkeys whose import or publishing failed in minutes (min 1).")
+	flag.Int64Var(&GPGKeyExpiryWarningDays, "gpgkey-expiry-warning-days", 14, "Days ahead of a gpgkey expiry to start warning about it (min 0).")
+	flag.Int64Var(&SopsSecretRequeueAfter, "sopssecret-requeue-after", 5, "Requeue sopssecrets waiting for a missing reference in minutes (min 1).")
+	flag.DurationVar(&SyncPeriod, "sync-period", 10*time.Hour, "Interval at which every object is reconciled again, correcting drift of child secrets.")
+	flag.Int64Var(&KeyRotationRequeueAfter, "keyrotation-requeue-after", 5, "Requeue keyrotations waiting for their gpgkeys in minutes (min 1).")
+	flag.StringVar(&SopsManifestAllowedKinds, "sopsmanifest-allowed-kinds", "", "Comma separated group/version/Kind of the namespaced objects sopsmanifests may apply, "+
+		"e.g. v1/ConfigMap,argoproj.io/v1alpha1/Application. The operator needs RBAC to manage them.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -76,6 +87,7 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "5c4f2e95.gitopssecret.snappcloud.io",
+		Cache:                  cache.Options{SyncPeriod: &SyncPeriod},
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -88,12 +100,25 @@ func main() {
 	if SopsSecretRequeueAfter < 1 {
 		SopsSecretRequeueAfter = 1
 	}
+	if KeyRotationRequeueAfter < 1 {
+		KeyRotationRequeueAfter = 1
+	}
+	if GPGKeyExpiryWarningDays < 0 {
+		GPGKeyExpiryWarningDays = 0
+	}
+	allowedKinds, err := controllers.ParseAllowedKinds(SopsManifestAllowedKinds)
+	if err != nil {
+		setupLog.Error(err, "unable to parse sopsmanifest-allowed-kinds")
+		os.Exit(1)
+	}
 
 	if err = (&controllers.GPGKeyReconciler{
-		Client:       mgr.GetClient(),
-		Scheme:       mgr.GetScheme(),
-		Log:          ctrl.Log.WithName("controllers").WithName("GPGKey"),
-		RequeueAfter: GPGKeyRequeueAfter,
+		Client:            mgr.GetClient(),
+		Scheme:            mgr.GetScheme(),
+		Log:               ctrl.Log.WithName("controllers").WithName("GPGKey"),
+		Recorder:          mgr.GetEventRecorderFor("gpgkey-controller"),
+		RequeueAfter:      GPGKeyRequeueAfter,
+		ExpiryWarningDays: GPGKeyExpiryWarningDays,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "GPGKey")
 		os.Exit(1)
@@ -102,11 +127,41 @@ func main() {
 		Client:       mgr.GetClient(),
 		Scheme:       mgr.GetScheme(),
 		Log:          ctrl.Log.WithName("controllers").WithName("SopsSecret"),
+		Recorder:     mgr.GetEventRecorderFor("sopssecret-controller"),
 		RequeueAfter: SopsSecretRequeueAfter,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "SopsSecret")
 		os.Exit(1)
 	}
+	if err = (&controllers.KeyRotationReconciler{
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		Log:          ctrl.Log.WithName("controllers").WithName("KeyRotation"),
+		RequeueAfter: KeyRotationRequeueAfter,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "KeyRotation")
+		os.Exit(1)
+	}
+	if err = (&controllers.SopsFileReconciler{
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		Log:          ctrl.Log.WithName("controllers").WithName("SopsFile"),
+		RequeueAfter: SopsSecretRequeueAfter,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SopsFile")
+		os.Exit(1)
+	}
+	if err = (&controllers.SopsManifestReconciler{
+		Client:       mgr.GetClient(),
+		Scheme:       mgr.GetScheme(),
+		Log:          ctrl.Log.WithName("controllers").WithName("SopsManifest"),
+		Recorder:     mgr.GetEventRecorderFor("sopsmanifest-controller"),
+		RequeueAfter: SopsSecretRequeueAfter,
+		AllowedKinds: allowedKinds,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SopsManifest")
+		os.Exit(1)
+	}
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")